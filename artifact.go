@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	foundation "github.com/estafette/estafette-foundation"
+	"github.com/rs/zerolog/log"
+)
+
+// artifactModifiedTime is set on every file in the archive instead of the checkout time, so packaging the same tree always yields the same archive
+var artifactModifiedTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// getArtifactPath returns the location of the source archive of the build version
+func getArtifactPath(bucket, app, buildVersion string) string {
+	return fmt.Sprintf("gs://%v/%v/%v.zip", bucket, app, buildVersion)
+}
+
+// writeSourceArchive zips the files in the source directory in lexical order, skipping what .gcloudignore ignores like gcloud does when uploading the source; file times are fixed and permissions reduced to executable or not
+func writeSourceArchive(source, runtime string, writer io.Writer) error {
+
+	patterns, err := readGcloudIgnorePatterns(source, runtime)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(writer)
+
+	err = filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		if relativePath == "." {
+			return nil
+		}
+
+		if isGcloudIgnored(patterns, filepath.ToSlash(relativePath), info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header := &zip.FileHeader{
+			Name:   filepath.ToSlash(relativePath),
+			Method: zip.Deflate,
+		}
+		header.Modified = artifactModifiedTime
+		if info.Mode()&0111 != 0 {
+			header.SetMode(0755)
+		} else {
+			header.SetMode(0644)
+		}
+
+		fileWriter, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(fileWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// packageFunction uploads the source as archive named after the build version, so every release deploys the same tree
+func packageFunction(ctx context.Context, params Params) {
+
+	if params.EntryPoint != "" && params.Runtime != "" {
+		log.Info().Msgf("Verifying entry point %v in source %v...", params.EntryPoint, params.Source)
+		err := verifyEntryPoint(params.Source, params.Runtime, params.Trigger, params.EntryPoint)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed verifying entry point")
+		}
+	}
+
+	file, err := ioutil.TempFile("", "source-*.zip")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed creating source archive")
+	}
+	defer os.Remove(file.Name())
+
+	log.Info().Msgf("Packaging source %v...", params.Source)
+	err = writeSourceArchive(params.Source, params.Runtime, file)
+	file.Close()
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed packaging source %v", params.Source)
+	}
+
+	artifactPath := getArtifactPath(params.ArtifactBucket, params.App, params.BuildVersion)
+
+	if params.DryRun {
+		log.Info().Msgf("Dry run uploading source archive to %v...", artifactPath)
+		return
+	}
+
+	log.Info().Msgf("Uploading source archive to %v...", artifactPath)
+	foundation.RunCommandWithArgs(ctx, "gsutil", []string{"cp", file.Name(), artifactPath})
+}
+
+// verifySourceArchive checks whether a source archive in cloud storage exists, to fail before deploying instead of halfway the deployment
+func verifySourceArchive(ctx context.Context, source string) error {
+	if !strings.HasPrefix(source, "gs://") {
+		return nil
+	}
+
+	err := foundation.RunCommandWithArgsExtended(ctx, "gsutil", []string{"-q", "stat", source})
+	if err != nil {
+		return fmt.Errorf("Source archive %v does not exist; package it in the build stage with action package", source)
+	}
+
+	return nil
+}
+
+// extractSourceArchive unzips the archive into the directory, refusing paths that would end up outside of it
+func extractSourceArchive(archivePath, directory string) error {
+
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		path := filepath.Join(directory, filepath.FromSlash(file.Name))
+		if !strings.HasPrefix(path, filepath.Clean(directory)+string(os.PathSeparator)) {
+			return fmt.Errorf("Source archive contains path %v outside of the archive root", file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			err = os.MkdirAll(path, 0755)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+		err = extractSourceFile(file, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractSourceFile(file *zip.File, path string) error {
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	mode := os.FileMode(0644)
+	if file.Mode()&0111 != 0 {
+		mode = 0755
+	}
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	_, err = io.Copy(writer, reader)
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSourceArchive(t *testing.T) {
+	t.Run("ReturnsSameArchiveForSameFilesWithDifferentTimes", func(t *testing.T) {
+
+		source, err := ioutil.TempDir("", "source")
+		assert.Nil(t, err)
+		defer os.RemoveAll(source)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "function.go"), []byte("package function\n"), 0644))
+		assert.Nil(t, os.Mkdir(filepath.Join(source, "lib"), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "lib", "lib.go"), []byte("package lib\n"), 0644))
+
+		first := &bytes.Buffer{}
+		assert.Nil(t, writeSourceArchive(source, "go121", first))
+		assert.Nil(t, os.Chtimes(filepath.Join(source, "function.go"), time.Now(), time.Now().Add(time.Hour)))
+
+		// act
+		second := &bytes.Buffer{}
+		err = writeSourceArchive(source, "go121", second)
+
+		assert.Nil(t, err)
+		assert.Equal(t, first.Bytes(), second.Bytes())
+	})
+
+	t.Run("SkipsGitDirectory", func(t *testing.T) {
+
+		source, err := ioutil.TempDir("", "source")
+		assert.Nil(t, err)
+		defer os.RemoveAll(source)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "function.go"), []byte("package function\n"), 0644))
+		assert.Nil(t, os.Mkdir(filepath.Join(source, ".git"), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644))
+
+		// act
+		buffer := &bytes.Buffer{}
+		err = writeSourceArchive(source, "go121", buffer)
+
+		assert.Nil(t, err)
+		archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(archive.File)) {
+			assert.Equal(t, "function.go", archive.File[0].Name)
+		}
+	})
+
+	t.Run("SkipsFilesIgnoredByGcloudIgnore", func(t *testing.T) {
+
+		source, err := ioutil.TempDir("", "source")
+		assert.Nil(t, err)
+		defer os.RemoveAll(source)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, ".gcloudignore"), []byte("# test data\n.gcloudignore\ntestdata/\n*_test.go\n"), 0644))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "function.go"), []byte("package function\n"), 0644))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "function_test.go"), []byte("package function\n"), 0644))
+		assert.Nil(t, os.Mkdir(filepath.Join(source, "testdata"), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "testdata", "event.json"), []byte("{}\n"), 0644))
+
+		// act
+		buffer := &bytes.Buffer{}
+		err = writeSourceArchive(source, "go121", buffer)
+
+		assert.Nil(t, err)
+		archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(archive.File)) {
+			assert.Equal(t, "function.go", archive.File[0].Name)
+		}
+	})
+
+	t.Run("SkipsNodeModulesForNodejsRuntimeWithoutGcloudIgnore", func(t *testing.T) {
+
+		source, err := ioutil.TempDir("", "source")
+		assert.Nil(t, err)
+		defer os.RemoveAll(source)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "index.js"), []byte("exports.handler = () => {}\n"), 0644))
+		assert.Nil(t, os.Mkdir(filepath.Join(source, "node_modules"), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "node_modules", "left-pad.js"), []byte("\n"), 0644))
+
+		// act
+		buffer := &bytes.Buffer{}
+		err = writeSourceArchive(source, "nodejs20", buffer)
+
+		assert.Nil(t, err)
+		archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(archive.File)) {
+			assert.Equal(t, "index.js", archive.File[0].Name)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CapturedFunction is the state of the function before deploying, to redeploy if verifying the deployment fails
+type CapturedFunction struct {
+	Function        *CloudFunction
+	SourceDirectory string
+}
+
+// Cleanup removes the downloaded source of the captured function
+func (c *CapturedFunction) Cleanup() {
+	if c == nil {
+		return
+	}
+	os.RemoveAll(c.SourceDirectory)
+}
+
+// captureFunction describes the deployed function and downloads its source; it returns nil if there's nothing to roll back to, and an error if it can't tell
+func captureFunction(ctx context.Context, params Params, credential GKECredentials) (*CapturedFunction, error) {
+
+	function, err := describeLiveFunction(ctx, params.App, credential.AdditionalProperties.Region, params.Generation)
+	if err != nil {
+		if !isNotFoundError(err) {
+			return nil, err
+		}
+		log.Warn().Msgf("Cloud function %v doesn't exist yet, so it can't be rolled back if verification fails", params.App)
+		return nil, nil
+	}
+
+	if changes := getImmutableChanges(function.ToParams(), params); len(changes) > 0 {
+		log.Warn().Msgf("Cloud function %v can't be rolled back if verification fails since %v", params.App, strings.Join(changes, " and "))
+		return nil, nil
+	}
+
+	sourceDirectory, err := ioutil.TempDir("", "captured")
+	if err != nil {
+		return nil, err
+	}
+	captured := &CapturedFunction{Function: function, SourceDirectory: sourceDirectory}
+
+	log.Info().Msgf("Capturing cloud function %v to roll back to if verification fails...", params.App)
+	archive, err := ioutil.TempFile("", "captured-*.zip")
+	if err != nil {
+		captured.Cleanup()
+		return nil, err
+	}
+	defer os.Remove(archive.Name())
+
+	err = downloadFunctionSource(ctx, function, "", archive)
+	archive.Close()
+	if err == nil {
+		err = extractSourceArchive(archive.Name(), sourceDirectory)
+	}
+	if err != nil {
+		captured.Cleanup()
+		return nil, err
+	}
+
+	return captured, nil
+}
+
+// getCapturedParams returns the params to redeploy the captured function with
+func getCapturedParams(params Params, captured *CapturedFunction) Params {
+
+	capturedParams := captured.Function.ToParams()
+	capturedParams.Action = params.Action
+	capturedParams.App = params.App
+	capturedParams.Source = captured.SourceDirectory
+	capturedParams.BuildVersion = captured.Function.Labels[buildVersionLabel]
+
+	return capturedParams
+}
+
+// rollbackAfterFailedVerification redeploys the captured function and fails the release, reporting both the verification failure and the outcome of the rollback
+func rollbackAfterFailedVerification(ctx context.Context, params Params, credential GKECredentials, captured *CapturedFunction, verificationErr error) {
+
+	log.Error().Err(verificationErr).Msgf("Verification of cloud function %v failed, rolling back...", params.App)
+
+	capturedParams := getCapturedParams(params, captured)
+	function, err := runDeploy(ctx, capturedParams, credential, nil, getLabelParams(captured.Function.GetUserLabels()), getRestoreArguments(capturedParams))
+	if err == nil {
+		err = checkFunctionStatus(function)
+	}
+	captured.Cleanup()
+	if err != nil {
+		log.Fatal().Err(verificationErr).Msgf("Verification of cloud function %v failed and rolling back failed as well: %v", params.App, err)
+	}
+
+	log.Fatal().Err(verificationErr).Msgf("Verification of cloud function %v failed; rolled back to version %v", params.App, capturedParams.BuildVersion)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCapturedParams(t *testing.T) {
+	t.Run("ReturnsParamsOfCapturedFunctionWithDownloadedSource", func(t *testing.T) {
+
+		params := Params{Action: "deploy", App: "my-app", Runtime: "go113", BuildVersion: "1.0.1"}
+		captured := &CapturedFunction{
+			Function: &CloudFunction{
+				Name:         "projects/my-project/locations/europe-west1/functions/my-app",
+				Runtime:      "go111",
+				HTTPSTrigger: &HTTPSTrigger{URL: "https://europe-west1-my-project.cloudfunctions.net/my-app"},
+				Labels:       map[string]string{buildVersionLabel: "1-0-0"},
+			},
+			SourceDirectory: "/tmp/captured",
+		}
+
+		// act
+		capturedParams := getCapturedParams(params, captured)
+
+		assert.Equal(t, "deploy", capturedParams.Action)
+		assert.Equal(t, "my-app", capturedParams.App)
+		assert.Equal(t, "go111", capturedParams.Runtime)
+		assert.Equal(t, "/tmp/captured", capturedParams.Source)
+		assert.Equal(t, "1-0-0", capturedParams.BuildVersion)
+	})
+}
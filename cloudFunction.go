@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CloudFunction represents the output of gcloud functions describe --format json for both generations
+type CloudFunction struct {
+	Name                       string                 `json:"name,omitempty"`
+	Status                     string                 `json:"status,omitempty"`
+	UpdateTime                 string                 `json:"updateTime,omitempty"`
+	Runtime                    string                 `json:"runtime,omitempty"`
+	EntryPoint                 string                 `json:"entryPoint,omitempty"`
+	AvailableMemoryMb          int                    `json:"availableMemoryMb,omitempty"`
+	Timeout                    string                 `json:"timeout,omitempty"`
+	ServiceAccountEmail        string                 `json:"serviceAccountEmail,omitempty"`
+	SourceArchiveURL           string                 `json:"sourceArchiveUrl,omitempty"`
+	HTTPSTrigger               *HTTPSTrigger          `json:"httpsTrigger,omitempty"`
+	EventTrigger               *EventTrigger          `json:"eventTrigger,omitempty"`
+	Labels                     map[string]string      `json:"labels,omitempty"`
+	EnvironmentVariables       map[string]string      `json:"environmentVariables,omitempty"`
+	BuildEnvironmentVariables  map[string]string      `json:"buildEnvironmentVariables,omitempty"`
+	BuildWorkerPool            string                 `json:"buildWorkerPool,omitempty"`
+	SecretEnvironmentVariables []SecretEnvironmentVar `json:"secretEnvironmentVariables,omitempty"`
+	SecretVolumes              []SecretVolume         `json:"secretVolumes,omitempty"`
+	IngressSettings            string                 `json:"ingressSettings,omitempty"`
+	VPCConnector               string                 `json:"vpcConnector,omitempty"`
+	VPCConnectorEgressSettings string                 `json:"vpcConnectorEgressSettings,omitempty"`
+	MaxInstances               int                    `json:"maxInstances,omitempty"`
+	MinInstances               int                    `json:"minInstances,omitempty"`
+	Environment                string                 `json:"environment,omitempty"`
+	State                      string                 `json:"state,omitempty"`
+	URL                        string                 `json:"url,omitempty"`
+	BuildConfig                *BuildConfig           `json:"buildConfig,omitempty"`
+	ServiceConfig              *ServiceConfig         `json:"serviceConfig,omitempty"`
+}
+
+// HTTPSTrigger contains the endpoint of a gen1 http function
+type HTTPSTrigger struct {
+	URL string `json:"url,omitempty"`
+}
+
+// EventTrigger contains the trigger of an event-driven function; gen1 uses eventType and resource, gen2 eventType and eventFilters
+type EventTrigger struct {
+	EventType           string        `json:"eventType,omitempty"`
+	Resource            string        `json:"resource,omitempty"`
+	PubsubTopic         string        `json:"pubsubTopic,omitempty"`
+	EventFilters        []EventFilter `json:"eventFilters,omitempty"`
+	TriggerRegion       string        `json:"triggerRegion,omitempty"`
+	ServiceAccountEmail string        `json:"serviceAccountEmail,omitempty"`
+	Channel             string        `json:"channel,omitempty"`
+}
+
+// EventFilter is a single eventarc filter of a gen2 event trigger
+type EventFilter struct {
+	Attribute string `json:"attribute,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Operator  string `json:"operator,omitempty"`
+}
+
+// SecretEnvironmentVar is a secret exposed as environment variable
+type SecretEnvironmentVar struct {
+	Key       string `json:"key,omitempty"`
+	ProjectID string `json:"projectId,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	Version   string `json:"version,omitempty"`
+}
+
+// SecretVolume is a secret mounted as file
+type SecretVolume struct {
+	MountPath string `json:"mountPath,omitempty"`
+	ProjectID string `json:"projectId,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	Versions  []struct {
+		Path    string `json:"path,omitempty"`
+		Version string `json:"version,omitempty"`
+	} `json:"versions,omitempty"`
+}
+
+// BuildConfig contains the build settings of a gen2 function
+type BuildConfig struct {
+	Runtime              string            `json:"runtime,omitempty"`
+	EntryPoint           string            `json:"entryPoint,omitempty"`
+	EnvironmentVariables map[string]string `json:"environmentVariables,omitempty"`
+	WorkerPool           string            `json:"workerPool,omitempty"`
+	ServiceAccount       string            `json:"serviceAccount,omitempty"`
+	Source               *struct {
+		StorageSource *struct {
+			Bucket string `json:"bucket,omitempty"`
+			Object string `json:"object,omitempty"`
+		} `json:"storageSource,omitempty"`
+	} `json:"source,omitempty"`
+}
+
+// ServiceConfig contains the cloud run service settings of a gen2 function
+type ServiceConfig struct {
+	Service                       string                 `json:"service,omitempty"`
+	URI                           string                 `json:"uri,omitempty"`
+	ServiceAccountEmail           string                 `json:"serviceAccountEmail,omitempty"`
+	AvailableMemory               string                 `json:"availableMemory,omitempty"`
+	AvailableCPU                  string                 `json:"availableCpu,omitempty"`
+	TimeoutSeconds                int                    `json:"timeoutSeconds,omitempty"`
+	MaxInstanceCount              int                    `json:"maxInstanceCount,omitempty"`
+	MinInstanceCount              int                    `json:"minInstanceCount,omitempty"`
+	MaxInstanceRequestConcurrency int                    `json:"maxInstanceRequestConcurrency,omitempty"`
+	EnvironmentVariables          map[string]string      `json:"environmentVariables,omitempty"`
+	SecretEnvironmentVariables    []SecretEnvironmentVar `json:"secretEnvironmentVariables,omitempty"`
+	SecretVolumes                 []SecretVolume         `json:"secretVolumes,omitempty"`
+	IngressSettings               string                 `json:"ingressSettings,omitempty"`
+	VPCConnector                  string                 `json:"vpcConnector,omitempty"`
+	VPCConnectorEgressSettings    string                 `json:"vpcConnectorEgressSettings,omitempty"`
+}
+
+// IsGen2 returns true if the function is a second generation function; gen1 functions described with --gen2 have an environment as well
+func (f *CloudFunction) IsGen2() bool {
+	if f.Environment != "" {
+		return f.Environment == "GEN_2"
+	}
+	return f.ServiceConfig != nil || f.BuildConfig != nil
+}
+
+// GetURL returns the https endpoint of the function, if it has one
+func (f *CloudFunction) GetURL() string {
+	if f.HTTPSTrigger != nil && f.HTTPSTrigger.URL != "" {
+		return f.HTTPSTrigger.URL
+	}
+	if f.URL != "" {
+		return f.URL
+	}
+	if f.ServiceConfig != nil {
+		return f.ServiceConfig.URI
+	}
+	return ""
+}
+
+// GetServiceAccountEmail returns the runtime service account of the function
+func (f *CloudFunction) GetServiceAccountEmail() string {
+	if f.ServiceConfig != nil && f.ServiceConfig.ServiceAccountEmail != "" {
+		return f.ServiceConfig.ServiceAccountEmail
+	}
+	return f.ServiceAccountEmail
+}
+
+// GetSource returns the location of the deployed source archive, if it's stored in cloud storage
+func (f *CloudFunction) GetSource() string {
+	if f.SourceArchiveURL != "" {
+		return f.SourceArchiveURL
+	}
+	if f.BuildConfig != nil && f.BuildConfig.Source != nil && f.BuildConfig.Source.StorageSource != nil {
+		return fmt.Sprintf("gs://%v/%v", f.BuildConfig.Source.StorageSource.Bucket, f.BuildConfig.Source.StorageSource.Object)
+	}
+	return ""
+}
+
+// GetUserLabels returns the labels without the ones reserved for deployment tools, which can't be set manually
+func (f *CloudFunction) GetUserLabels() map[string]string {
+	labels := map[string]string{}
+	for k, v := range f.Labels {
+		if !strings.HasPrefix(k, "deployment") {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// ToParams maps the deployed function to the params that would deploy it the same way
+func (f *CloudFunction) ToParams() Params {
+
+	params := Params{
+		App:             f.Name[strings.LastIndex(f.Name, "/")+1:],
+		Generation:      1,
+		Source:          f.GetSource(),
+		ServiceAccount:  f.GetServiceAccountEmail(),
+		EnvironmentMode: "replace",
+	}
+
+	runtime, entryPoint, vpcConnector := f.Runtime, f.EntryPoint, f.VPCConnector
+	ingressSettings, egressSettings := f.IngressSettings, f.VPCConnectorEgressSettings
+	environmentVariables, buildEnvironmentVariables := f.EnvironmentVariables, f.BuildEnvironmentVariables
+	secretEnvironmentVariables, secretVolumes := f.SecretEnvironmentVariables, f.SecretVolumes
+	params.BuildWorkerPool = f.BuildWorkerPool
+	params.MinInstances, params.MaxInstances = f.MinInstances, f.MaxInstances
+
+	if f.IsGen2() {
+		params.Generation = 2
+		if f.BuildConfig != nil {
+			runtime, entryPoint = f.BuildConfig.Runtime, f.BuildConfig.EntryPoint
+			buildEnvironmentVariables = f.BuildConfig.EnvironmentVariables
+			params.BuildWorkerPool = f.BuildConfig.WorkerPool
+			params.BuildServiceAccount = f.BuildConfig.ServiceAccount
+		}
+		if f.ServiceConfig != nil {
+			params.Memory = normalizeGen2Memory(f.ServiceConfig.AvailableMemory)
+			params.CPU = f.ServiceConfig.AvailableCPU
+			params.TimeoutSeconds = f.ServiceConfig.TimeoutSeconds
+			params.MinInstances, params.MaxInstances = f.ServiceConfig.MinInstanceCount, f.ServiceConfig.MaxInstanceCount
+			if f.ServiceConfig.MaxInstanceRequestConcurrency > 1 {
+				params.Concurrency = f.ServiceConfig.MaxInstanceRequestConcurrency
+			}
+			vpcConnector = f.ServiceConfig.VPCConnector
+			ingressSettings, egressSettings = f.ServiceConfig.IngressSettings, f.ServiceConfig.VPCConnectorEgressSettings
+			environmentVariables = f.ServiceConfig.EnvironmentVariables
+			secretEnvironmentVariables, secretVolumes = f.ServiceConfig.SecretEnvironmentVariables, f.ServiceConfig.SecretVolumes
+		}
+	} else {
+		params.Memory = fmt.Sprintf("%vMB", f.AvailableMemoryMb)
+		if timeout, err := strconv.Atoi(strings.TrimSuffix(f.Timeout, "s")); err == nil {
+			params.TimeoutSeconds = timeout
+		}
+	}
+
+	params.Runtime = runtime
+	params.EntryPoint = entryPoint
+	params.VPCConnector = vpcConnector
+
+	switch ingressSettings {
+	case "ALLOW_INTERNAL_ONLY":
+		params.IngressSettings = "internal-only"
+	case "ALLOW_INTERNAL_AND_GCLB":
+		params.IngressSettings = "internal-and-gclb"
+	default:
+		params.IngressSettings = "all"
+	}
+
+	switch egressSettings {
+	case "ALL_TRAFFIC":
+		params.EgressSettings = "all"
+	default:
+		params.EgressSettings = "private-ranges-only"
+	}
+
+	if len(environmentVariables) > 0 {
+		params.EnvironmentVariables = map[string]interface{}{}
+		for k, v := range environmentVariables {
+			params.EnvironmentVariables[k] = v
+		}
+	} else {
+		params.EnvironmentMode = "clear"
+	}
+
+	if len(buildEnvironmentVariables) > 0 {
+		params.BuildEnvironmentVariables = map[string]interface{}{}
+		for k, v := range buildEnvironmentVariables {
+			params.BuildEnvironmentVariables[k] = v
+		}
+	}
+
+	if len(secretEnvironmentVariables) > 0 || len(secretVolumes) > 0 {
+		params.Secrets = map[string]string{}
+		for _, s := range secretEnvironmentVariables {
+			params.Secrets[s.Key] = fmt.Sprintf("projects/%v/secrets/%v/versions/%v", s.ProjectID, s.Secret, s.Version)
+		}
+		for _, s := range secretVolumes {
+			for _, v := range s.Versions {
+				params.Secrets[strings.TrimSuffix(s.MountPath, "/")+"/"+strings.TrimPrefix(v.Path, "/")] = fmt.Sprintf("projects/%v/secrets/%v/versions/%v", s.ProjectID, s.Secret, v.Version)
+			}
+		}
+		params.AllowCrossProjectSecrets = true
+	}
+
+	f.setTriggerParams(&params)
+
+	return params
+}
+
+func (f *CloudFunction) setTriggerParams(params *Params) {
+
+	if f.EventTrigger == nil {
+		params.Trigger = "http"
+		return
+	}
+
+	t := f.EventTrigger
+	resourceName := func(resource string) string {
+		return resource[strings.LastIndex(resource, "/")+1:]
+	}
+
+	switch {
+	case t.PubsubTopic != "":
+		params.Trigger = "topic"
+		params.TriggerValue = resourceName(t.PubsubTopic)
+	case inStringArray(t.EventType, []string{"google.pubsub.topic.publish", "providers/cloud.pubsub/eventTypes/topic.publish"}):
+		params.Trigger = "topic"
+		params.TriggerValue = resourceName(t.Resource)
+	case t.EventType == "google.storage.object.finalize" && strings.HasPrefix(t.Resource, "projects/_/buckets/"):
+		params.Trigger = "bucket"
+		params.TriggerValue = resourceName(t.Resource)
+	case t.Resource != "":
+		params.Trigger = "event"
+		params.TriggerEvent = t.EventType
+		params.TriggerResource = t.Resource
+	default:
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": t.EventType}
+		for _, filter := range t.EventFilters {
+			if filter.Operator == "match-path-pattern" {
+				if params.EventFiltersPathPattern == nil {
+					params.EventFiltersPathPattern = map[string]string{}
+				}
+				params.EventFiltersPathPattern[filter.Attribute] = filter.Value
+			} else {
+				params.EventFilters[filter.Attribute] = filter.Value
+			}
+		}
+		params.TriggerLocation = t.TriggerRegion
+		params.TriggerServiceAccount = t.ServiceAccountEmail
+		params.TriggerChannel = t.Channel
+
+		// gen2 bucket triggers are plain eventarc triggers on the finalized event
+		if t.EventType == "google.cloud.storage.object.v1.finalized" && len(params.EventFilters) == 2 && params.EventFilters["bucket"] != "" && len(params.EventFiltersPathPattern) == 0 {
+			params.Trigger = "bucket"
+			params.TriggerValue = params.EventFilters["bucket"]
+			params.EventFilters = nil
+			params.TriggerLocation = ""
+			params.TriggerServiceAccount = ""
+		}
+	}
+}
+
+// normalizeGen2Memory converts memory as reported by the api, like 256M or 1G, to the format used in params
+func normalizeGen2Memory(memory string) string {
+	matches := regexp.MustCompile(`^([0-9]+)(M|G|Mi|Gi)$`).FindStringSubmatch(memory)
+	if matches == nil {
+		return memory
+	}
+	return matches[1] + string(matches[2][0]) + "i"
+}
+
+// describeFunction retrieves the deployed function; it returns an error if the function doesn't exist or can't be retrieved; extra arguments like --project and --account describe it in another project
+func describeFunction(ctx context.Context, name, region string, generation int, extraArguments ...string) (*CloudFunction, string, error) {
+
+	describeArguments := []string{
+		"functions",
+		"describe", name,
+		"--region", region,
+		"--format", "json"}
+
+	if generation == 2 {
+		describeArguments = append(describeArguments, "--gen2")
+	}
+	describeArguments = append(describeArguments, extraArguments...)
+
+	output, err := getCommandWithArgsOutput(ctx, "gcloud", describeArguments)
+	if err != nil {
+		return nil, output, err
+	}
+
+	var function CloudFunction
+	err = json.Unmarshal([]byte(output), &function)
+	if err != nil {
+		return nil, output, err
+	}
+
+	return &function, output, nil
+}
+
+// isNotFoundError returns true if gcloud failed because the function doesn't exist, as opposed to failing to authenticate or reach the api
+func isNotFoundError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "NOT_FOUND") || strings.Contains(message, "status=[404]") || strings.Contains(message, "does not exist") || strings.Contains(message, "was not found")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToParams(t *testing.T) {
+	t.Run("ReturnsParamsForGeneration1HttpFunction", func(t *testing.T) {
+
+		var function CloudFunction
+		err := json.Unmarshal([]byte(`{
+			"name": "projects/my-project/locations/europe-west1/functions/myfunction",
+			"runtime": "go113",
+			"entryPoint": "Handle",
+			"availableMemoryMb": 512,
+			"timeout": "120s",
+			"serviceAccountEmail": "myfunction@my-project.iam.gserviceaccount.com",
+			"sourceArchiveUrl": "gs://my-sources/myfunction.zip",
+			"httpsTrigger": {"url": "https://europe-west1-my-project.cloudfunctions.net/myfunction"},
+			"environmentVariables": {"KEY": "value"},
+			"ingressSettings": "ALLOW_INTERNAL_ONLY",
+			"vpcConnectorEgressSettings": "ALL_TRAFFIC",
+			"labels": {"app": "myfunction", "deployment-tool": "cli-gcloud"}
+		}`), &function)
+		assert.Nil(t, err)
+
+		// act
+		params := function.ToParams()
+
+		assert.Equal(t, "myfunction", params.App)
+		assert.Equal(t, 1, params.Generation)
+		assert.Equal(t, "go113", params.Runtime)
+		assert.Equal(t, "Handle", params.EntryPoint)
+		assert.Equal(t, "512MB", params.Memory)
+		assert.Equal(t, 120, params.TimeoutSeconds)
+		assert.Equal(t, "gs://my-sources/myfunction.zip", params.Source)
+		assert.Equal(t, "http", params.Trigger)
+		assert.Equal(t, "internal-only", params.IngressSettings)
+		assert.Equal(t, "all", params.EgressSettings)
+		assert.Equal(t, "replace", params.EnvironmentMode)
+		assert.Equal(t, "value", params.EnvironmentVariables["KEY"])
+		assert.Equal(t, map[string]string{"app": "myfunction"}, function.GetUserLabels())
+	})
+
+	t.Run("ReturnsParamsForGeneration2TopicFunction", func(t *testing.T) {
+
+		var function CloudFunction
+		err := json.Unmarshal([]byte(`{
+			"name": "projects/my-project/locations/europe-west1/functions/myfunction",
+			"environment": "GEN_2",
+			"buildConfig": {
+				"runtime": "python311",
+				"entryPoint": "handle",
+				"source": {"storageSource": {"bucket": "gcf-v2-sources", "object": "myfunction/function-source.zip"}}
+			},
+			"serviceConfig": {
+				"availableMemory": "1G",
+				"availableCpu": "1",
+				"timeoutSeconds": 300,
+				"maxInstanceRequestConcurrency": 10,
+				"secretEnvironmentVariables": [{"key": "TOKEN", "projectId": "my-project", "secret": "token", "version": "2"}]
+			},
+			"eventTrigger": {
+				"eventType": "google.cloud.pubsub.topic.v1.messagePublished",
+				"pubsubTopic": "projects/my-project/topics/mytopic"
+			}
+		}`), &function)
+		assert.Nil(t, err)
+
+		// act
+		params := function.ToParams()
+
+		assert.Equal(t, 2, params.Generation)
+		assert.Equal(t, "python311", params.Runtime)
+		assert.Equal(t, "1Gi", params.Memory)
+		assert.Equal(t, "1", params.CPU)
+		assert.Equal(t, 10, params.Concurrency)
+		assert.Equal(t, 300, params.TimeoutSeconds)
+		assert.Equal(t, "gs://gcf-v2-sources/myfunction/function-source.zip", params.Source)
+		assert.Equal(t, "topic", params.Trigger)
+		assert.Equal(t, "mytopic", params.TriggerValue)
+		assert.Equal(t, "clear", params.EnvironmentMode)
+		assert.Equal(t, "projects/my-project/secrets/token/versions/2", params.Secrets["TOKEN"])
+	})
+}
+
+func TestIsNotFoundError(t *testing.T) {
+	t.Run("ReturnsTrueForGen1NotFound", func(t *testing.T) {
+
+		err := fmt.Errorf("exit status 1: ERROR: (gcloud.functions.describe) ResponseError: status=[404], code=[Not Found], message=[Function myfunction in region europe-west1 in project my-project does not exist]")
+
+		// act
+		notFound := isNotFoundError(err)
+
+		assert.True(t, notFound)
+	})
+
+	t.Run("ReturnsTrueForGen2NotFound", func(t *testing.T) {
+
+		err := fmt.Errorf("exit status 1: ERROR: (gcloud.functions.describe) NOT_FOUND: Resource 'projects/my-project/locations/europe-west1/functions/myfunction' was not found")
+
+		// act
+		notFound := isNotFoundError(err)
+
+		assert.True(t, notFound)
+	})
+
+	t.Run("ReturnsFalseForPermissionDenied", func(t *testing.T) {
+
+		err := fmt.Errorf("exit status 1: ERROR: (gcloud.functions.describe) PERMISSION_DENIED: Permission 'cloudfunctions.functions.get' denied")
+
+		// act
+		notFound := isNotFoundError(err)
+
+		assert.False(t, notFound)
+	})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// getCommandWithArgsOutput runs a single command with the arguments like foundation.RunCommandWithArgsExtended, but returns its standard output instead of printing it; on error the standard error is added to the error
+func getCommandWithArgsOutput(ctx context.Context, command string, args []string) (string, error) {
+	log.Debug().Msgf("> %v %v", command, strings.Join(args, " "))
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Env = os.Environ()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return stdout.String(), fmt.Errorf("%v: %v", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// verifyEntryPoint checks whether the source contains the entry point for the runtime, so a typo fails before a slow cloud build instead of after
+func verifyEntryPoint(source, runtime, trigger, entryPoint string) error {
+
+	if strings.HasPrefix(source, "gs://") || strings.HasPrefix(source, "https://") {
+		return nil
+	}
+
+	candidates, err := findEntryPointCandidates(source, runtime, trigger)
+	if err != nil {
+		return err
+	}
+
+	if inStringArray(entryPoint, candidates) {
+		return nil
+	}
+
+	if len(candidates) == 0 {
+		return fmt.Errorf("Entry point %v is not found in source %v for runtime %v; no candidate functions were found", entryPoint, source, runtime)
+	}
+
+	return fmt.Errorf("Entry point %v is not found in source %v for runtime %v; found candidates %v", entryPoint, source, runtime, strings.Join(candidates, ", "))
+}
+
+func findEntryPointCandidates(source, runtime, trigger string) ([]string, error) {
+	switch {
+	case strings.HasPrefix(runtime, "go"):
+		return findGoEntryPoints(source, trigger)
+	case strings.HasPrefix(runtime, "nodejs"):
+		return findNodeEntryPoints(source)
+	case strings.HasPrefix(runtime, "python"):
+		return findPythonEntryPoints(source)
+	}
+
+	return nil, fmt.Errorf("Runtime %v is not supported for entry point verification", runtime)
+}
+
+// findGoEntryPoints returns the exported functions in the root package with an http or background function signature, and the names registered with the functions framework
+func findGoEntryPoints(source, trigger string) ([]string, error) {
+
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(fileSet, source, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{}
+	for _, pkg := range packages {
+		if pkg.Name == "main" {
+			continue
+		}
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				funcDecl, ok := decl.(*ast.FuncDecl)
+				if !ok || funcDecl.Recv != nil || !funcDecl.Name.IsExported() {
+					continue
+				}
+				if trigger == "http" && isGoHTTPFunction(funcDecl.Type) || trigger != "http" && isGoBackgroundFunction(funcDecl.Type) {
+					candidates = append(candidates, funcDecl.Name.Name)
+				}
+			}
+
+			// gen2 functions register their entry point by name, for example functions.HTTP("Name", handler)
+			ast.Inspect(file, func(node ast.Node) bool {
+				call, ok := node.(*ast.CallExpr)
+				if !ok || len(call.Args) != 2 {
+					return true
+				}
+				selector, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || selector.Sel.Name != "HTTP" && selector.Sel.Name != "CloudEvent" {
+					return true
+				}
+				if name, ok := call.Args[0].(*ast.BasicLit); ok && name.Kind == token.STRING {
+					candidates = append(candidates, strings.Trim(name.Value, "\"`"))
+				}
+				return true
+			})
+		}
+	}
+
+	sort.Strings(candidates)
+	return candidates, nil
+}
+
+// isGoHTTPFunction checks for func(w http.ResponseWriter, r *http.Request)
+func isGoHTTPFunction(funcType *ast.FuncType) bool {
+	params := flattenGoFields(funcType.Params)
+	if len(params) != 2 || funcType.Results != nil && len(funcType.Results.List) > 0 {
+		return false
+	}
+
+	writer, ok := params[0].(*ast.SelectorExpr)
+	if !ok || writer.Sel.Name != "ResponseWriter" {
+		return false
+	}
+	request, ok := params[1].(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	requestSelector, ok := request.X.(*ast.SelectorExpr)
+	return ok && requestSelector.Sel.Name == "Request"
+}
+
+// isGoBackgroundFunction checks for func(ctx context.Context, e T) error
+func isGoBackgroundFunction(funcType *ast.FuncType) bool {
+	params := flattenGoFields(funcType.Params)
+	results := flattenGoFields(funcType.Results)
+	if len(params) != 2 || len(results) != 1 {
+		return false
+	}
+
+	ctx, ok := params[0].(*ast.SelectorExpr)
+	if !ok || ctx.Sel.Name != "Context" {
+		return false
+	}
+	result, ok := results[0].(*ast.Ident)
+	return ok && result.Name == "error"
+}
+
+// flattenGoFields returns one type per parameter, so func(a, b string) yields two types
+func flattenGoFields(fields *ast.FieldList) []ast.Expr {
+	types := []ast.Expr{}
+	if fields == nil {
+		return types
+	}
+	for _, field := range fields.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// findNodeEntryPoints returns the members exported by the main module, as set in package.json or index.js by default
+func findNodeEntryPoints(source string) ([]string, error) {
+
+	mainFile := "index.js"
+	if packageJSON, err := ioutil.ReadFile(filepath.Join(source, "package.json")); err == nil {
+		if matches := regexp.MustCompile(`"main"\s*:\s*"([^"]+)"`).FindSubmatch(packageJSON); matches != nil {
+			mainFile = string(matches[1])
+		}
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(source, mainFile))
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := map[string]bool{}
+	for _, matches := range regexp.MustCompile(`(?m)(?:^|[^\w.])(?:module\.)?exports\.([A-Za-z_$][\w$]*)\s*=`).FindAllStringSubmatch(string(content), -1) {
+		candidates[matches[1]] = true
+	}
+	for _, matches := range regexp.MustCompile(`functions\.(?:http|cloudEvent)\(\s*['"]([^'"]+)['"]`).FindAllStringSubmatch(string(content), -1) {
+		candidates[matches[1]] = true
+	}
+	for _, matches := range regexp.MustCompile(`module\.exports\s*=\s*\{([^}]*)\}`).FindAllStringSubmatch(string(content), -1) {
+		for _, member := range strings.Split(matches[1], ",") {
+			name := strings.TrimSpace(strings.SplitN(member, ":", 2)[0])
+			if regexp.MustCompile(`^[A-Za-z_$][\w$]*$`).MatchString(name) {
+				candidates[name] = true
+			}
+		}
+	}
+
+	return sortedSet(candidates), nil
+}
+
+// findPythonEntryPoints returns the top-level functions in main.py
+func findPythonEntryPoints(source string) ([]string, error) {
+
+	content, err := ioutil.ReadFile(filepath.Join(source, "main.py"))
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := map[string]bool{}
+	for _, matches := range regexp.MustCompile(`(?m)^(?:async\s+)?def\s+([A-Za-z_]\w*)\s*\(`).FindAllStringSubmatch(string(content), -1) {
+		candidates[matches[1]] = true
+	}
+
+	return sortedSet(candidates), nil
+}
+
+func sortedSet(set map[string]bool) []string {
+	values := make([]string, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
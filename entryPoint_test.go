@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSourceFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "entrypoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestVerifyEntryPoint(t *testing.T) {
+
+	goSource := `package function
+
+import (
+	"context"
+	"net/http"
+)
+
+func HelloHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func HelloPubSub(ctx context.Context, m PubSubMessage) error { return nil }
+
+func helper(w http.ResponseWriter, r *http.Request) {}
+
+type PubSubMessage struct{}
+`
+
+	t.Run("ReturnsNilIfGoHTTPFunctionExists", func(t *testing.T) {
+
+		dir := writeSourceFiles(t, map[string]string{"function.go": goSource})
+		defer os.RemoveAll(dir)
+
+		// act
+		err := verifyEntryPoint(dir, "go113", "http", "HelloHTTP")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorWithCandidatesIfGoFunctionDoesNotExist", func(t *testing.T) {
+
+		dir := writeSourceFiles(t, map[string]string{"function.go": goSource})
+		defer os.RemoveAll(dir)
+
+		// act
+		err := verifyEntryPoint(dir, "go113", "http", "HelloHttp")
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "HelloHTTP")
+		assert.NotContains(t, err.Error(), "helper")
+	})
+
+	t.Run("ReturnsErrorIfGoFunctionHasSignatureForOtherTrigger", func(t *testing.T) {
+
+		dir := writeSourceFiles(t, map[string]string{"function.go": goSource})
+		defer os.RemoveAll(dir)
+
+		// act
+		err := verifyEntryPoint(dir, "go113", "topic", "HelloHTTP")
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "HelloPubSub")
+	})
+
+	t.Run("ReturnsNilIfGoFunctionIsRegisteredWithFunctionsFramework", func(t *testing.T) {
+
+		dir := writeSourceFiles(t, map[string]string{"function.go": `package function
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() {
+	functions.HTTP("HelloWorld", helloWorld)
+}
+`})
+		defer os.RemoveAll(dir)
+
+		// act
+		err := verifyEntryPoint(dir, "go113", "http", "HelloWorld")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsNilIfNodeExportExists", func(t *testing.T) {
+
+		dir := writeSourceFiles(t, map[string]string{"index.js": `exports.helloHttp = (req, res) => {};
+module.exports.helloEvent = async (event) => {};
+`})
+		defer os.RemoveAll(dir)
+
+		// act
+		err := verifyEntryPoint(dir, "nodejs10", "http", "helloEvent")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsNilIfNodeModuleExportsMemberExistsInMainFromPackageJSON", func(t *testing.T) {
+
+		dir := writeSourceFiles(t, map[string]string{
+			"package.json": `{"name": "fn", "main": "app.js"}`,
+			"app.js":       "module.exports = { helloHttp, other: handler };\n",
+		})
+		defer os.RemoveAll(dir)
+
+		// act
+		err := verifyEntryPoint(dir, "nodejs10", "http", "other")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsNilIfPythonTopLevelFunctionExists", func(t *testing.T) {
+
+		dir := writeSourceFiles(t, map[string]string{"main.py": `import os
+
+def hello_http(request):
+    def nested():
+        pass
+    return "ok"
+`})
+		defer os.RemoveAll(dir)
+
+		// act
+		err := verifyEntryPoint(dir, "python37", "http", "hello_http")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfPythonFunctionIsNotTopLevel", func(t *testing.T) {
+
+		dir := writeSourceFiles(t, map[string]string{"main.py": `def hello_http(request):
+    def nested():
+        pass
+`})
+		defer os.RemoveAll(dir)
+
+		// act
+		err := verifyEntryPoint(dir, "python37", "http", "nested")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsNilForSourceInCloudStorage", func(t *testing.T) {
+
+		// act
+		err := verifyEntryPoint("gs://bucket/source.zip", "go113", "http", "Anything")
+
+		assert.Nil(t, err)
+	})
+}
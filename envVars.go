@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// getEnvironmentVariableValue converts a value from the manifest into the string set as environment variable; numbers and booleans become their literal string, objects and arrays compact json
+func getEnvironmentVariableValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	default:
+		return marshalJSON(v)
+	}
+}
+
+// marshalJSON returns compact json without escaping html characters
+func marshalJSON(value interface{}) (string, error) {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+// generateEnvVarsYAML renders the environment variables as a yaml map with sorted keys; keys and values are written as double-quoted scalars, which share their escaping rules with json strings
+func generateEnvVarsYAML(envvars map[string]interface{}) ([]byte, error) {
+
+	keys := make([]string, 0, len(envvars))
+	for k := range envvars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buffer := &bytes.Buffer{}
+	for _, k := range keys {
+		value, err := getEnvironmentVariableValue(envvars[k])
+		if err != nil {
+			return nil, fmt.Errorf("Failed serializing environment variable %v: %v", k, err)
+		}
+
+		quotedKey, err := marshalJSON(k)
+		if err != nil {
+			return nil, err
+		}
+		quotedValue, err := marshalJSON(value)
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.WriteString(fmt.Sprintf("%v: %v\n", quotedKey, quotedValue))
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// writeEnvVarsFile writes the environment variables to a temporary yaml file to pass to --env-vars-file
+func writeEnvVarsFile(envvars map[string]interface{}) (string, error) {
+
+	content, err := generateEnvVarsYAML(envvars)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := ioutil.TempFile("", "env-vars-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	err = file.Chmod(0600)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = file.Write(content)
+	if err != nil {
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// validateEnvironmentMode mirrors the mutually exclusive env var flags of gcloud functions deploy
+func (p *Params) validateEnvironmentMode() (errors []error, warnings []string) {
+
+	supportedEnvironmentModes := []string{
+		"replace",
+		"merge",
+		"clear",
+	}
+
+	if !inStringArray(p.EnvironmentMode, supportedEnvironmentModes) {
+		errors = append(errors, fmt.Errorf("EnvMode %v is not supported; set it to %v", p.EnvironmentMode, strings.Join(supportedEnvironmentModes, ", ")))
+		return
+	}
+
+	if len(p.RemoveEnvironment) > 0 && p.EnvironmentMode != "merge" {
+		errors = append(errors, fmt.Errorf("RemoveEnv can only be used when EnvMode is merge; gcloud only allows --remove-env-vars together with --update-env-vars"))
+	}
+
+	switch p.EnvironmentMode {
+	case "clear":
+		if len(p.EnvironmentVariables) > 0 {
+			errors = append(errors, fmt.Errorf("Env can't be set when EnvMode is clear; remove env or set EnvMode to replace or merge"))
+		}
+	case "merge":
+		for _, key := range p.RemoveEnvironment {
+			if _, ok := p.EnvironmentVariables[key]; ok {
+				errors = append(errors, fmt.Errorf("Environment variable %v is set in both env and removeEnv; remove it from one of them", key))
+			}
+		}
+		if len(p.EnvironmentVariables) == 0 && len(p.RemoveEnvironment) == 0 {
+			warnings = append(warnings, "EnvMode is merge but neither env nor removeEnv is set; environment variables are left untouched")
+		}
+	}
+
+	return
+}
+
+// getEnvVarsArgument returns the key=value pairs for flags like --update-env-vars; if any pair contains a comma it switches to an alternative delimiter as described in gcloud topic escaping
+func getEnvVarsArgument(envvars map[string]interface{}) (string, error) {
+
+	keys := make([]string, 0, len(envvars))
+	for k := range envvars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		value, err := getEnvironmentVariableValue(envvars[k])
+		if err != nil {
+			return "", fmt.Errorf("Failed serializing environment variable %v: %v", k, err)
+		}
+		pairs = append(pairs, fmt.Sprintf("%v=%v", k, value))
+	}
+
+	concatenated := strings.Join(pairs, "")
+	if !strings.Contains(concatenated, ",") {
+		return strings.Join(pairs, ","), nil
+	}
+
+	for _, delimiter := range []string{"|", "@", "#", "~", ";", ":"} {
+		if !strings.Contains(concatenated, delimiter) {
+			return fmt.Sprintf("^%v^%v", delimiter, strings.Join(pairs, delimiter)), nil
+		}
+	}
+
+	return "", fmt.Errorf("Environment variables contain all of the delimiters | @ # ~ ; :, so they can't be passed as argument")
+}
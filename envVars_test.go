@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEnvironmentVariableValue(t *testing.T) {
+
+	t.Run("ReturnsStringAsIs", func(t *testing.T) {
+
+		// act
+		value, err := getEnvironmentVariableValue("a,b=c\nd")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "a,b=c\nd", value)
+	})
+
+	t.Run("ReturnsNumberAsLiteralString", func(t *testing.T) {
+
+		// act
+		value, err := getEnvironmentVariableValue(float64(8080))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "8080", value)
+	})
+
+	t.Run("ReturnsFractionAsLiteralString", func(t *testing.T) {
+
+		// act
+		value, err := getEnvironmentVariableValue(0.25)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "0.25", value)
+	})
+
+	t.Run("ReturnsBooleanAsLiteralString", func(t *testing.T) {
+
+		// act
+		value, err := getEnvironmentVariableValue(true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "true", value)
+	})
+
+	t.Run("ReturnsObjectAsCompactJSONWithSortedKeys", func(t *testing.T) {
+
+		// act
+		value, err := getEnvironmentVariableValue(map[string]interface{}{"b": "<x>", "a": []interface{}{float64(1), "two"}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, `{"a":[1,"two"],"b":"<x>"}`, value)
+	})
+
+	t.Run("ReturnsEmptyStringForNull", func(t *testing.T) {
+
+		// act
+		value, err := getEnvironmentVariableValue(nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "", value)
+	})
+}
+
+func TestGenerateEnvVarsYAML(t *testing.T) {
+
+	t.Run("ReturnsSortedDoubleQuotedKeysAndValues", func(t *testing.T) {
+
+		envvars := map[string]interface{}{
+			"MYSECRET":   "pa\"ss,word=\n",
+			"CONNECTION": "host=db;user=app",
+			"PORT":       float64(8080),
+			"CONFIG":     map[string]interface{}{"a": "b"},
+		}
+
+		// act
+		content, err := generateEnvVarsYAML(envvars)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "\"CONFIG\": \"{\\\"a\\\":\\\"b\\\"}\"\n\"CONNECTION\": \"host=db;user=app\"\n\"MYSECRET\": \"pa\\\"ss,word=\\n\"\n\"PORT\": \"8080\"\n", string(content))
+	})
+}
+
+func TestGetEnvVarsArgument(t *testing.T) {
+
+	t.Run("ReturnsCommaSeparatedPairsIfNoValueContainsAComma", func(t *testing.T) {
+
+		envvars := map[string]interface{}{
+			"B": "2",
+			"A": float64(1),
+		}
+
+		// act
+		argument, err := getEnvVarsArgument(envvars)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "A=1,B=2", argument)
+	})
+
+	t.Run("ReturnsPairsWithAlternativeDelimiterIfAValueContainsAComma", func(t *testing.T) {
+
+		envvars := map[string]interface{}{
+			"HOSTS": "a,b",
+			"MODE":  "x|y",
+		}
+
+		// act
+		argument, err := getEnvVarsArgument(envvars)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "^@^HOSTS=a,b@MODE=x|y", argument)
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+)
+
+// EventType describes an event type usable with --trigger-event and the format of the resource it expects for --trigger-resource
+type EventType struct {
+	ResourceFormat  string
+	resourcePattern *regexp.Regexp
+}
+
+var (
+	bucketResource            = EventType{ResourceFormat: "bucketName", resourcePattern: regexp.MustCompile(`^[a-z0-9][a-z0-9-_.]{1,220}[a-z0-9]$`)}
+	topicResource             = EventType{ResourceFormat: "topicName or projects/{project}/topics/{topic}", resourcePattern: regexp.MustCompile(`^(projects/[a-z][a-z0-9-]{4,28}[a-z0-9]/topics/)?[a-zA-Z][a-zA-Z0-9-_.~+%]{2,254}$`)}
+	firestoreDocumentResource = EventType{ResourceFormat: "projects/{project}/databases/(default)/documents/{collection}/{document}", resourcePattern: regexp.MustCompile(`^projects/[a-z][a-z0-9-]{4,28}[a-z0-9]/databases/\(default\)/documents/[^/]+(/[^/]+)+$`)}
+	firebaseProjectResource   = EventType{ResourceFormat: "projects/{project}", resourcePattern: regexp.MustCompile(`^projects/[a-z][a-z0-9-]{4,28}[a-z0-9]$`)}
+	firebaseDatabaseResource  = EventType{ResourceFormat: "projects/_/instances/{instance}/refs/{path}", resourcePattern: regexp.MustCompile(`^projects/_/instances/[a-z0-9-]+/refs(/[^/]+)+$`)}
+	firebaseAnalyticsResource = EventType{ResourceFormat: "projects/{project}/events/{event}", resourcePattern: regexp.MustCompile(`^projects/[a-z][a-z0-9-]{4,28}[a-z0-9]/events/[a-zA-Z0-9_]+$`)}
+
+	// supportedEventTypes lists the event types as returned by gcloud functions event-types list
+	supportedEventTypes = map[string]EventType{
+		"google.storage.object.finalize":                           bucketResource,
+		"google.storage.object.delete":                             bucketResource,
+		"google.storage.object.archive":                            bucketResource,
+		"google.storage.object.metadataUpdate":                     bucketResource,
+		"providers/cloud.storage/eventTypes/object.change":         bucketResource,
+		"google.pubsub.topic.publish":                              topicResource,
+		"providers/cloud.pubsub/eventTypes/topic.publish":          topicResource,
+		"providers/cloud.firestore/eventTypes/document.create":     firestoreDocumentResource,
+		"providers/cloud.firestore/eventTypes/document.update":     firestoreDocumentResource,
+		"providers/cloud.firestore/eventTypes/document.delete":     firestoreDocumentResource,
+		"providers/cloud.firestore/eventTypes/document.write":      firestoreDocumentResource,
+		"providers/firebase.auth/eventTypes/user.create":           firebaseProjectResource,
+		"providers/firebase.auth/eventTypes/user.delete":           firebaseProjectResource,
+		"google.firebase.remoteconfig.update":                      firebaseProjectResource,
+		"providers/google.firebase.database/eventTypes/ref.create": firebaseDatabaseResource,
+		"providers/google.firebase.database/eventTypes/ref.update": firebaseDatabaseResource,
+		"providers/google.firebase.database/eventTypes/ref.delete": firebaseDatabaseResource,
+		"providers/google.firebase.database/eventTypes/ref.write":  firebaseDatabaseResource,
+		"providers/google.firebase.analytics/eventTypes/event.log": firebaseAnalyticsResource,
+	}
+)
+
+// IsValidResource checks whether the resource matches the format expected by the event type
+func (e EventType) IsValidResource(resource string) bool {
+	return e.resourcePattern.MatchString(resource)
+}
+
+func getSupportedEventTypeNames() []string {
+	names := make([]string, 0, len(supportedEventTypes))
+	for name := range supportedEventTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	auditLogEventType    = "google.cloud.audit.log.v1.written"
+	firestoreEventPrefix = "google.cloud.firestore.document.v1."
+	storageEventPrefix   = "google.cloud.storage.object.v1."
+	pubsubEventType      = "google.cloud.pubsub.topic.v1.messagePublished"
+	eventFilterTypeKey   = "type"
+)
+
+func (p *Params) validateEventarcProperties() (errors []error) {
+
+	if p.Trigger != "eventarc" {
+		if len(p.EventFilters) > 0 || len(p.EventFiltersPathPattern) > 0 || p.TriggerLocation != "" || p.TriggerServiceAccount != "" || p.TriggerChannel != "" {
+			errors = append(errors, fmt.Errorf("EventFilters, EventFiltersPathPattern, TriggerLocation, TriggerServiceAccount and TriggerChannel can only be used when Trigger is eventarc"))
+		}
+		return
+	}
+
+	if p.Generation != 2 {
+		errors = append(errors, fmt.Errorf("Trigger eventarc is only supported for generation 2; set generation to 2"))
+	}
+
+	eventType, hasType := p.EventFilters[eventFilterTypeKey]
+	if !hasType || eventType == "" {
+		errors = append(errors, fmt.Errorf("EventFilters requires a type filter when Trigger is eventarc; set it to the event type, for example %v", auditLogEventType))
+	}
+
+	attributeReg := regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*$`)
+	for _, key := range sortedKeys(p.EventFilters) {
+		if !attributeReg.MatchString(key) {
+			errors = append(errors, fmt.Errorf("EventFilters attribute %v is not valid; it has to start with a letter and contain only letters and numbers", key))
+		}
+		if p.EventFilters[key] == "" {
+			errors = append(errors, fmt.Errorf("EventFilters attribute %v has an empty value", key))
+		}
+	}
+
+	for _, key := range sortedKeys(p.EventFiltersPathPattern) {
+		if !attributeReg.MatchString(key) {
+			errors = append(errors, fmt.Errorf("EventFiltersPathPattern attribute %v is not valid; it has to start with a letter and contain only letters and numbers", key))
+		}
+		if _, ok := p.EventFilters[key]; ok {
+			errors = append(errors, fmt.Errorf("Attribute %v is set in both EventFilters and EventFiltersPathPattern; set it in only one of them", key))
+		}
+
+		switch {
+		case key == eventFilterTypeKey:
+			errors = append(errors, fmt.Errorf("Attribute type can't be a path pattern; set it in EventFilters"))
+		case eventType == auditLogEventType && key == "resourceName":
+		case strings.HasPrefix(eventType, firestoreEventPrefix) && key == "document":
+		default:
+			errors = append(errors, fmt.Errorf("EventFiltersPathPattern attribute %v is not supported for event type %v; only resourceName for audit log events and document for firestore events support path patterns", key, eventType))
+		}
+	}
+
+	switch {
+	case eventType == auditLogEventType:
+		for _, key := range []string{"serviceName", "methodName"} {
+			if _, ok := p.EventFilters[key]; !ok {
+				errors = append(errors, fmt.Errorf("EventFilters requires a %v filter for event type %v", key, auditLogEventType))
+			}
+		}
+		if p.TriggerChannel != "" {
+			errors = append(errors, fmt.Errorf("TriggerChannel can't be used for event type %v", auditLogEventType))
+		}
+	case strings.HasPrefix(eventType, storageEventPrefix):
+		if _, ok := p.EventFilters["bucket"]; !ok {
+			errors = append(errors, fmt.Errorf("EventFilters requires a bucket filter for event type %v", eventType))
+		}
+	case eventType == pubsubEventType:
+		errors = append(errors, fmt.Errorf("Event type %v can't be used with Trigger eventarc; set Trigger to topic instead", pubsubEventType))
+	}
+
+	if p.TriggerChannel != "" && !regexp.MustCompile(`^(projects/[a-z][a-z0-9-]{4,28}[a-z0-9]/locations/[a-z0-9-]+/channels/)?[a-z][a-z0-9-]{0,62}$`).MatchString(p.TriggerChannel) {
+		errors = append(errors, fmt.Errorf("TriggerChannel %v is not valid; set it to a channel name or projects/{project}/locations/{location}/channels/{channel}", p.TriggerChannel))
+	}
+
+	if p.TriggerLocation != "" && !regexp.MustCompile(`^[a-z]+[0-9]*(-[a-z]+[0-9]+)?$`).MatchString(p.TriggerLocation) {
+		errors = append(errors, fmt.Errorf("TriggerLocation %v is not valid; set it to a region like europe-west1, a multi-region like nam5 or eur3, or to global", p.TriggerLocation))
+	}
+
+	if p.TriggerServiceAccount != "" && !regexp.MustCompile(`^[^@\s]+@[^@\s]+\.iam\.gserviceaccount\.com$|^[0-9]+-compute@developer\.gserviceaccount\.com$`).MatchString(p.TriggerServiceAccount) {
+		errors = append(errors, fmt.Errorf("TriggerServiceAccount %v is not a valid service account email", p.TriggerServiceAccount))
+	}
+
+	return errors
+}
+
+// getEventFilterArguments returns the attribute=value pairs in a stable order to pass to --trigger-event-filters
+func getEventFilterArguments(filters map[string]string) string {
+	pairs := []string{}
+	for _, key := range sortedKeys(filters) {
+		pairs = append(pairs, fmt.Sprintf("%v=%v", key, filters[key]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
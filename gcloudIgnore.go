@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	foundation "github.com/estafette/estafette-foundation"
+)
+
+const gcloudIgnoreFile = ".gcloudignore"
+
+// GcloudIgnorePattern is a line of a .gcloudignore file, which uses the syntax of .gitignore
+type GcloudIgnorePattern struct {
+	regex         *regexp.Regexp
+	negate        bool
+	directoryOnly bool
+}
+
+// getDefaultGcloudIgnoreLines returns the lines gcloud uses if the source has no .gcloudignore file
+func getDefaultGcloudIgnoreLines(source, runtime string) []string {
+	lines := []string{".gcloudignore", ".git", ".gitignore"}
+	if strings.HasPrefix(runtime, "nodejs") {
+		lines = append(lines, "node_modules")
+	}
+	if foundation.FileExists(filepath.Join(source, ".gitignore")) {
+		lines = append(lines, "#!include:.gitignore")
+	}
+	return lines
+}
+
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// readGcloudIgnorePatterns reads the .gcloudignore file of the source, including the files it includes, or the defaults of gcloud if there is none
+func readGcloudIgnorePatterns(source, runtime string) ([]GcloudIgnorePattern, error) {
+
+	lines := getDefaultGcloudIgnoreLines(source, runtime)
+	if path := filepath.Join(source, gcloudIgnoreFile); foundation.FileExists(path) {
+		var err error
+		lines, err = readLines(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	patterns := []GcloudIgnorePattern{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#!include:") {
+			includedLines, err := readLines(filepath.Join(source, strings.TrimSpace(strings.TrimPrefix(line, "#!include:"))))
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, parseGcloudIgnoreLines(includedLines)...)
+			continue
+		}
+		patterns = append(patterns, parseGcloudIgnoreLines([]string{line})...)
+	}
+
+	return patterns, nil
+}
+
+// parseGcloudIgnoreLines turns the lines into patterns matching slash separated paths relative to the source, skipping comments and blank lines
+func parseGcloudIgnoreLines(lines []string) []GcloudIgnorePattern {
+
+	patterns := []GcloudIgnorePattern{}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern := GcloudIgnorePattern{}
+		if strings.HasPrefix(line, "!") {
+			pattern.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			pattern.directoryOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		// a pattern with a slash other than at the end is relative to the source, otherwise it matches at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		expression := regexp.QuoteMeta(line)
+		expression = strings.Replace(expression, `\*\*/`, `(.*/)?`, -1)
+		expression = strings.Replace(expression, `/\*\*`, `(/.*)?`, -1)
+		expression = strings.Replace(expression, `\*\*`, `.*`, -1)
+		expression = strings.Replace(expression, `\*`, `[^/]*`, -1)
+		expression = strings.Replace(expression, `\?`, `[^/]`, -1)
+		if anchored {
+			expression = "^" + expression + "$"
+		} else {
+			expression = "(^|/)" + expression + "$"
+		}
+
+		pattern.regex = regexp.MustCompile(expression)
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns
+}
+
+// isGcloudIgnored returns true if the last pattern matching the relative path ignores it
+func isGcloudIgnored(patterns []GcloudIgnorePattern, relativePath string, isDirectory bool) bool {
+	ignored := false
+	for _, pattern := range patterns {
+		if pattern.directoryOnly && !isDirectory {
+			continue
+		}
+		if pattern.regex.MatchString(relativePath) {
+			ignored = !pattern.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsGcloudIgnored(t *testing.T) {
+	t.Run("ReturnsTrueForNameAtAnyDepth", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"*.log"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "logs/debug.log", false)
+
+		assert.True(t, ignored)
+	})
+
+	t.Run("ReturnsFalseForNonMatchingName", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"*.log"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "main.go", false)
+
+		assert.False(t, ignored)
+	})
+
+	t.Run("ReturnsTrueForAnchoredPathFromSource", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"/build"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "build", true)
+
+		assert.True(t, ignored)
+	})
+
+	t.Run("ReturnsFalseForAnchoredPathInSubdirectory", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"/build"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "lib/build", true)
+
+		assert.False(t, ignored)
+	})
+
+	t.Run("ReturnsFalseForDirectoryPatternMatchingFile", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"testdata/"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "testdata", false)
+
+		assert.False(t, ignored)
+	})
+
+	t.Run("ReturnsTrueForDirectoryPatternMatchingDirectory", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"testdata/"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "lib/testdata", true)
+
+		assert.True(t, ignored)
+	})
+
+	t.Run("ReturnsTrueForDoubleStarPattern", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"docs/**/*.md"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "docs/api/index.md", false)
+
+		assert.True(t, ignored)
+	})
+
+	t.Run("ReturnsFalseForNegatedLaterPattern", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"*.json", "!package.json"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "package.json", false)
+
+		assert.False(t, ignored)
+	})
+
+	t.Run("SkipsComments", func(t *testing.T) {
+
+		patterns := parseGcloudIgnoreLines([]string{"# main.go"})
+
+		// act
+		ignored := isGcloudIgnored(patterns, "main.go", false)
+
+		assert.False(t, ignored)
+	})
+}
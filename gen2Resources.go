@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Gen2Memory is a memory size supported by second generation functions with the cpu gcloud assigns to it by default
+type Gen2Memory struct {
+	MebiBytes  int
+	DefaultCPU string
+}
+
+var (
+	// see https://cloud.google.com/functions/docs/configuring/memory
+	supportedGen2Memory = []Gen2Memory{
+		{MebiBytes: 128, DefaultCPU: "0.083"},
+		{MebiBytes: 256, DefaultCPU: "0.167"},
+		{MebiBytes: 512, DefaultCPU: "0.333"},
+		{MebiBytes: 1024, DefaultCPU: "0.583"},
+		{MebiBytes: 2048, DefaultCPU: "1"},
+		{MebiBytes: 4096, DefaultCPU: "2"},
+		{MebiBytes: 8192, DefaultCPU: "2"},
+		{MebiBytes: 16384, DefaultCPU: "4"},
+		{MebiBytes: 32768, DefaultCPU: "8"},
+	}
+
+	// whole cpu values can be combined with memory between these bounds (in MiB)
+	gen2WholeCPUMemoryBounds = map[string][2]int{
+		"1": {128, 4096},
+		"2": {128, 8192},
+		"4": {2048, 16384},
+		"6": {4096, 24576},
+		"8": {4096, 32768},
+	}
+)
+
+// parseGen2Memory converts memory like 512MB, 512Mi, 2GB or 2Gi into MiB
+func parseGen2Memory(memory string) (int, error) {
+	reg := regexp.MustCompile(`^([0-9]+)(MB|Mi|GB|Gi)$`)
+	matches := reg.FindStringSubmatch(memory)
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("Memory %v has an invalid format; use a number followed by MB, Mi, GB or Gi", memory)
+	}
+
+	value, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, err
+	}
+
+	switch matches[2] {
+	case "GB", "Gi":
+		return value * 1024, nil
+	default:
+		return value, nil
+	}
+}
+
+// getGen2Memory returns the supported gen2 memory matching the memory param
+func getGen2Memory(memory string) (*Gen2Memory, error) {
+	mebiBytes, err := parseGen2Memory(memory)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range supportedGen2Memory {
+		if m.MebiBytes == mebiBytes {
+			return &m, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Memory %v is not supported for generation 2; set it to one of 128Mi, 256Mi, 512Mi, 1Gi, 2Gi, 4Gi, 8Gi, 16Gi or 32Gi", memory)
+}
+
+// getSupportedGen2CPU returns the cpu values that can be combined with the gen2 memory
+func getSupportedGen2CPU(memory Gen2Memory) []string {
+	supportedCPU := []string{}
+	if _, isWholeCPU := gen2WholeCPUMemoryBounds[memory.DefaultCPU]; !isWholeCPU {
+		supportedCPU = append(supportedCPU, memory.DefaultCPU)
+	}
+
+	for _, cpu := range []string{"1", "2", "4", "6", "8"} {
+		bounds := gen2WholeCPUMemoryBounds[cpu]
+		if memory.MebiBytes >= bounds[0] && memory.MebiBytes <= bounds[1] {
+			supportedCPU = append(supportedCPU, cpu)
+		}
+	}
+
+	return supportedCPU
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // GKECredentials represents the credentials of type kubernetes-engine as defined in the server config and passed to this trusted image
 type GKECredentials struct {
 	Name                 string                            `json:"name,omitempty"`
@@ -28,3 +33,20 @@ func GetCredentialsByName(c []GKECredentials, credentialName string) *GKECredent
 
 	return nil
 }
+
+// GetClientEmail returns the service account email from the keyfile
+func (c *GKECredentials) GetClientEmail() (string, error) {
+
+	var keyFile struct {
+		ClientEmail string `json:"client_email"`
+	}
+	err := json.Unmarshal([]byte(c.AdditionalProperties.ServiceAccountKeyfile), &keyFile)
+	if err != nil {
+		return "", err
+	}
+	if keyFile.ClientEmail == "" {
+		return "", fmt.Errorf("Field client_email missing from service account keyfile of credential %v", c.Name)
+	}
+
+	return keyFile.ClientEmail, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// ServiceAccountKey contains the fields of a service account keyfile needed to sign tokens
+type ServiceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+}
+
+func parseServiceAccountKey(keyFile string) (*ServiceAccountKey, *rsa.PrivateKey, error) {
+
+	var key ServiceAccountKey
+	err := json.Unmarshal([]byte(keyFile), &key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, nil, fmt.Errorf("Field private_key of service account keyfile is not a pem encoded key")
+	}
+
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsedKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	privateKey, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("Field private_key of service account keyfile is not an rsa key")
+	}
+
+	return &key, privateKey, nil
+}
+
+// signJWT returns the claims as jwt signed with RS256
+func signJWT(claims map[string]interface{}, keyID string, privateKey *rsa.PrivateKey) (string, error) {
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// getIDTokenAssertion signs the assertion that is exchanged for an id token with the audience
+func getIDTokenAssertion(keyFile, audience string, now time.Time) (string, error) {
+
+	key, privateKey, err := parseServiceAccountKey(keyFile)
+	if err != nil {
+		return "", err
+	}
+
+	return signJWT(map[string]interface{}{
+		"iss":             key.ClientEmail,
+		"sub":             key.ClientEmail,
+		"aud":             googleTokenURL,
+		"target_audience": audience,
+		"iat":             now.Unix(),
+		"exp":             now.Add(time.Hour).Unix(),
+	}, key.PrivateKeyID, privateKey)
+}
+
+// getIDToken signs an assertion with the service account key and exchanges it for an id token to invoke the function at the audience url with
+func getIDToken(ctx context.Context, keyFile, audience string) (string, error) {
+
+	assertion, err := getIDTokenAssertion(keyFile, audience, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	request, err := http.NewRequest(http.MethodPost, googleTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	err = doJSONRequest(request.WithContext(ctx), &token)
+	if err != nil {
+		return "", err
+	}
+	if token.IDToken == "" {
+		return "", fmt.Errorf("Token endpoint returned no id token")
+	}
+
+	return token.IDToken, nil
+}
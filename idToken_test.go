@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetIDTokenAssertion(t *testing.T) {
+	t.Run("ReturnsAssertionSignedWithServiceAccountKey", func(t *testing.T) {
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.Nil(t, err)
+		keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		assert.Nil(t, err)
+		keyFile, err := json.Marshal(ServiceAccountKey{
+			ClientEmail:  "deployer@my-project.iam.gserviceaccount.com",
+			PrivateKeyID: "mykeyid",
+			PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})),
+		})
+		assert.Nil(t, err)
+
+		// act
+		assertion, err := getIDTokenAssertion(string(keyFile), "https://europe-west1-my-project.cloudfunctions.net/myfunction", time.Unix(1600000000, 0))
+
+		assert.Nil(t, err)
+		parts := strings.Split(assertion, ".")
+		if assert.Equal(t, 3, len(parts)) {
+			hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+			assert.Nil(t, err)
+			assert.Nil(t, rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, hash[:], signature))
+
+			payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+			assert.Nil(t, err)
+			var claims map[string]interface{}
+			assert.Nil(t, json.Unmarshal(payload, &claims))
+			assert.Equal(t, "deployer@my-project.iam.gserviceaccount.com", claims["iss"])
+			assert.Equal(t, "https://europe-west1-my-project.cloudfunctions.net/myfunction", claims["target_audience"])
+			assert.Equal(t, float64(1600003600), claims["exp"])
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// crashSignatures match log lines of a function that fails to start, whatever severity they're logged with
+var crashSignatures = []string{
+	`panic: `,
+	`ModuleNotFoundError`,
+	`Cannot find module`,
+}
+
+// maxLoggedLogEntries limits the offending log entries printed by the log scan
+const maxLoggedLogEntries = 20
+
+// isErrorLevel returns true for log entries with severity error or higher; gcloud abbreviates the severity to its first letter
+func isErrorLevel(level string) bool {
+	switch strings.ToUpper(level) {
+	case "E", "ERROR", "C", "CRITICAL", "A", "ALERT", "EMERGENCY":
+		return true
+	}
+	return false
+}
+
+// findOffendingLogEntries returns the entries with severity error or higher and the entries matching a crash signature or one of the patterns
+func findOffendingLogEntries(entries []LogEntry, patterns []string) []LogEntry {
+
+	regexes := []*regexp.Regexp{}
+	for _, pattern := range append(crashSignatures, patterns...) {
+		regexes = append(regexes, regexp.MustCompile(pattern))
+	}
+
+	offendingEntries := []LogEntry{}
+	for _, entry := range entries {
+		if isErrorLevel(entry.Level) {
+			offendingEntries = append(offendingEntries, entry)
+			continue
+		}
+		for _, reg := range regexes {
+			if reg.MatchString(entry.Log) {
+				offendingEntries = append(offendingEntries, entry)
+				break
+			}
+		}
+	}
+
+	return offendingEntries
+}
+
+// getDeployedTime returns the time the function got updated, or now if the function doesn't tell
+func getDeployedTime(function *CloudFunction, now time.Time) time.Time {
+	if deployedTime, err := time.Parse(time.RFC3339Nano, function.UpdateTime); err == nil {
+		return deployedTime
+	}
+	return now
+}
+
+// runLogScan waits for the window to pass since deploying and scans the logs written in it; it returns an error if an offending entry is found, unless set to warn
+func runLogScan(ctx context.Context, params Params, credential GKECredentials, function *CloudFunction) error {
+
+	window, _ := time.ParseDuration(params.LogScan.Window)
+	start := getDeployedTime(function, time.Now())
+
+	if wait := time.Until(start.Add(window)); wait > 0 {
+		log.Info().Msgf("Waiting %v to scan the logs of cloud function %v...", wait.Round(time.Second), params.App)
+		time.Sleep(wait)
+	}
+
+	log.Info().Msgf("Scanning logs of cloud function %v since %v...", params.App, start.UTC().Format(time.RFC3339))
+	entries, err := readFunctionLogs(ctx, params, credential.AdditionalProperties.Region, "", start)
+	if err != nil {
+		return fmt.Errorf("Failed reading logs of cloud function %v: %v", params.App, err)
+	}
+
+	offendingEntries := findOffendingLogEntries(entries, params.LogScan.Patterns)
+	if len(offendingEntries) == 0 {
+		log.Info().Msgf("Log scan found no errors in %v log entries", len(entries))
+		return nil
+	}
+
+	for i, entry := range offendingEntries {
+		if i == maxLoggedLogEntries {
+			log.Warn().Msgf("  ...and %v more", len(offendingEntries)-maxLoggedLogEntries)
+			break
+		}
+		log.Warn().Msgf("  %v %v %v", entry.TimeUTC, entry.Level, entry.Log)
+	}
+
+	if params.LogScan.OnMatch == "warn" {
+		log.Warn().Msgf("Log scan found %v errors in the logs of cloud function %v", len(offendingEntries), params.App)
+		return nil
+	}
+
+	return fmt.Errorf("Log scan found %v errors in the logs of cloud function %v", len(offendingEntries), params.App)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// LogScanParam is used to scan the logs of the function for errors and crashes after deploying it
+type LogScanParam struct {
+	Window   string   `json:"window,omitempty"`
+	OnMatch  string   `json:"onMatch,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// SetDefaults fills in empty fields with convention-based defaults
+func (p *LogScanParam) SetDefaults() {
+
+	// default window to a couple of minutes, long enough for the first cold starts
+	if p.Window == "" {
+		p.Window = "2m"
+	}
+
+	// default to failing the release on errors
+	if p.OnMatch == "" {
+		p.OnMatch = "fail"
+	}
+}
+
+// ValidateRequiredProperties checks whether all needed properties are set
+func (p *LogScanParam) ValidateRequiredProperties() (bool, []error) {
+
+	errors := []error{}
+
+	if window, err := time.ParseDuration(p.Window); err != nil || window <= 0 {
+		errors = append(errors, fmt.Errorf("Log scan window %v is not a valid duration; set it to a duration like 2m", p.Window))
+	}
+
+	if p.OnMatch != "fail" && p.OnMatch != "warn" {
+		errors = append(errors, fmt.Errorf("Log scan onMatch %v is not supported; set it to fail or warn", p.OnMatch))
+	}
+
+	for _, pattern := range p.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errors = append(errors, fmt.Errorf("Log scan pattern %v is not a valid regular expression: %v", pattern, err))
+		}
+	}
+
+	return len(errors) == 0, errors
+}
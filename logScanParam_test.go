@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogScanParamSetDefaults(t *testing.T) {
+	t.Run("DefaultsToFailingOnErrorsInTwoMinutes", func(t *testing.T) {
+
+		params := LogScanParam{}
+
+		// act
+		params.SetDefaults()
+
+		assert.Equal(t, "2m", params.Window)
+		assert.Equal(t, "fail", params.OnMatch)
+	})
+}
+
+func TestLogScanParamValidateRequiredProperties(t *testing.T) {
+
+	validLogScanParam := LogScanParam{
+		Window:   "5m",
+		OnMatch:  "warn",
+		Patterns: []string{`connection refused`},
+	}
+
+	t.Run("ReturnsTrueIfAllPropertiesAreValid", func(t *testing.T) {
+
+		params := validLogScanParam
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfWindowIsNotValid", func(t *testing.T) {
+
+		params := validLogScanParam
+		params.Window = "5"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfOnMatchIsNotSupported", func(t *testing.T) {
+
+		params := validLogScanParam
+		params.OnMatch = "ignore"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfPatternIsNotValid", func(t *testing.T) {
+
+		params := validLogScanParam
+		params.Patterns = []string{`(refused`}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindOffendingLogEntries(t *testing.T) {
+	t.Run("ReturnsEntriesWithSeverityErrorOrHigher", func(t *testing.T) {
+
+		entries := []LogEntry{
+			{Level: "I", Log: "Function execution started"},
+			{Level: "E", Log: "Unhandled rejection"},
+			{Level: "C", Log: "Out of memory"},
+		}
+
+		// act
+		offendingEntries := findOffendingLogEntries(entries, nil)
+
+		assert.Equal(t, 2, len(offendingEntries))
+		assert.Equal(t, "Unhandled rejection", offendingEntries[0].Log)
+		assert.Equal(t, "Out of memory", offendingEntries[1].Log)
+	})
+
+	t.Run("ReturnsEntriesMatchingCrashSignatureWhateverTheirSeverity", func(t *testing.T) {
+
+		entries := []LogEntry{
+			{Level: "I", Log: "panic: runtime error: invalid memory address or nil pointer dereference"},
+			{Level: "D", Log: "ModuleNotFoundError: No module named 'requests'"},
+			{Level: "I", Log: "Error: Cannot find module 'express'"},
+			{Level: "I", Log: "Function execution took 12 ms"},
+		}
+
+		// act
+		offendingEntries := findOffendingLogEntries(entries, nil)
+
+		assert.Equal(t, 3, len(offendingEntries))
+	})
+
+	t.Run("ReturnsEntriesMatchingPattern", func(t *testing.T) {
+
+		entries := []LogEntry{
+			{Level: "W", Log: "dial tcp 10.0.0.1:5432: connection refused"},
+			{Level: "W", Log: "Slow query"},
+		}
+
+		// act
+		offendingEntries := findOffendingLogEntries(entries, []string{`connection refused`})
+
+		assert.Equal(t, 1, len(offendingEntries))
+		assert.Equal(t, "dial tcp 10.0.0.1:5432: connection refused", offendingEntries[0].Log)
+	})
+}
+
+func TestGetDeployedTime(t *testing.T) {
+	t.Run("ReturnsUpdateTimeOfFunction", func(t *testing.T) {
+
+		function := &CloudFunction{UpdateTime: "2020-03-04T10:11:12.345Z"}
+
+		// act
+		deployedTime := getDeployedTime(function, time.Now())
+
+		assert.Equal(t, time.Date(2020, 3, 4, 10, 11, 12, 345000000, time.UTC), deployedTime.UTC())
+	})
+
+	t.Run("ReturnsNowIfUpdateTimeIsNotSet", func(t *testing.T) {
+
+		now := time.Date(2020, 3, 4, 10, 11, 12, 0, time.UTC)
+
+		// act
+		deployedTime := getDeployedTime(&CloudFunction{}, now)
+
+		assert.Equal(t, now, deployedTime)
+	})
+}
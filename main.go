@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 	foundation "github.com/estafette/estafette-foundation"
@@ -32,6 +33,7 @@ var (
 
 	// optional flags
 	gitName       = kingpin.Flag("git-name", "Repository name, used as application name if not passed explicitly and app label not being set.").Envar("ESTAFETTE_GIT_NAME").String()
+	gitBranch     = kingpin.Flag("git-branch", "Branch name, used to name preview functions after.").Envar("ESTAFETTE_GIT_BRANCH").String()
 	appLabel      = kingpin.Flag("app-name", "App label, used as application name if not passed explicitly.").Envar("ESTAFETTE_LABEL_APP").String()
 	buildVersion  = kingpin.Flag("build-version", "Version number, used if not passed explicitly.").Envar("ESTAFETTE_BUILD_VERSION").String()
 	releaseName   = kingpin.Flag("release-name", "Name of the release section, which is used by convention to resolve the credentials.").Envar("ESTAFETTE_RELEASE_NAME").String()
@@ -124,12 +126,12 @@ func main() {
 	}
 
 	log.Info().Msg("Setting defaults for parameters that are not set in the manifest...")
-	params.SetDefaults(*gitName, *appLabel, *buildVersion, *releaseName, *releaseAction, estafetteLabels)
+	params.SetDefaults(*gitName, *gitBranch, *appLabel, *buildVersion, *releaseName, *releaseAction, estafetteLabels)
 
 	log.Info().Msg("Validating required parameters...")
-	valid, errors, warnings := params.ValidateRequiredProperties()
+	valid, errors, warnings := params.ValidateRequiredProperties(*credential)
 	if !valid {
-		log.Fatal().Err(err).Msg("Not all valid fields are set")
+		log.Fatal().Msgf("Not all valid fields are set: %v", errors)
 	}
 
 	for _, warning := range warnings {
@@ -171,12 +173,466 @@ func main() {
 	log.Info().Msg("Setting gcloud project")
 	foundation.RunCommandWithArgs(ctx, "gcloud", []string{"config", "set", "project", credential.AdditionalProperties.Project})
 
-	// prepare to pass labels as argument
+	// label functions with the repository as well, to tell pipelines deploying the same app apart
+	if _, ok := estafetteLabels["repo"]; !ok && *gitName != "" {
+		estafetteLabels["repo"] = *gitName
+	}
+
+	// sanitize labels to pass them as argument
 	estafetteLabels = sanitizeLabels(estafetteLabels)
-	labelParams := []string{}
-	for k, v := range estafetteLabels {
-		labelParams = append(labelParams, fmt.Sprintf("%v=%v", k, v))
+
+	switch {
+	case params.Action == "delete":
+		deleteFunction(ctx, params, *credential, estafetteLabels)
+	case params.Action == "describe":
+		describeFunctionStatus(ctx, params, *credential)
+	case params.Action == "verify":
+		verifyFunction(ctx, params, *credential, estafetteLabels)
+	case params.Action == "prune":
+		pruneFunctions(ctx, params, *credential, estafetteLabels)
+	case params.Action == "cleanup-previews":
+		cleanupPreviews(ctx, params, *credential, estafetteLabels)
+	case params.Action == "package":
+		packageFunction(ctx, params)
+	case params.Action == "promote":
+		sourceCredential := GetCredentialsByName(credentials, params.PromoteFrom)
+		if sourceCredential == nil {
+			log.Fatal().Msgf("Credential with name %v to promote from does not exist.", params.PromoteFrom)
+		}
+		promoteFunction(ctx, params, *credential, *sourceCredential, estafetteLabels)
+	case params.Action == "rollback" && params.SnapshotBucket != "":
+		rollbackFunction(ctx, params, *credential)
+	default:
+		deployFunction(ctx, params, *credential, estafetteLabels)
+	}
+}
+
+// deployFunction creates or updates the function and the resources it manages; without a snapshot bucket the rollback action redeploys the configuration of the released build version the same way
+func deployFunction(ctx context.Context, params Params, credential GKECredentials, labels map[string]string) {
+
+	if params.EntryPoint != "" {
+		log.Info().Msgf("Verifying entry point %v in source %v...", params.EntryPoint, params.Source)
+		err := verifyEntryPoint(params.Source, params.Runtime, params.Trigger, params.EntryPoint)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed verifying entry point")
+		}
+	}
+
+	// check the source archive before snapshotting or capturing, so a missing archive fails without side effects
+	err := verifySourceArchive(ctx, params.Source)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed verifying source")
+	}
+
+	guardOwnership(ctx, params, credential, labels)
+
+	if params.SnapshotBucket != "" && params.Action == "deploy" && !params.DryRun {
+		snapshotKey, err := snapshotFunction(ctx, params, credential)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Failed storing snapshot of cloud function %v", params.App)
+		}
+		if snapshotKey != "" {
+			if markErr := markSnapshotKnownGood(ctx, params, snapshotKey); markErr != nil {
+				log.Warn().Err(markErr).Msgf("Failed marking snapshot %v of cloud function %v as known-good", snapshotKey, params.App)
+			}
+		}
+	}
+
+	deployLabels := getDeployLabels(params, labels, time.Now())
+
+	var captured *CapturedFunction
+	if params.AutoRollback && !params.DryRun {
+		captured, err = captureFunction(ctx, params, credential)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Failed capturing cloud function %v to roll back to", params.App)
+		}
+		defer captured.Cleanup()
+	}
+
+	baseline := readBaselineMetrics(ctx, params, credential)
+
+	var recreation *Recreation
+	if !params.DryRun {
+		recreation, err = getRecreation(ctx, params, credential, labels)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Failed preparing deployment of cloud function %v", params.App)
+		}
+	}
+
+	function, err := runDeploy(ctx, params, credential, recreation, getLabelParams(deployLabels), nil)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed deploying cloud function %v", params.App)
+	}
+	if function == nil {
+		if planFunction(ctx, params, credential, deployLabels) {
+			os.Exit(driftExitCode)
+		}
+		return
+	}
+
+	if recreation != nil {
+		restoreIAMPolicy(ctx, params, credential.AdditionalProperties.Region, recreation.Policy)
+	}
+
+	applySchedule(ctx, params, credential.AdditionalProperties.Region, function)
+
+	err = verifyDeployment(ctx, params, credential, function, baseline)
+	if err == nil {
+		if params.SnapshotBucket != "" {
+			if markErr := clearDeploymentFailed(ctx, params, function); markErr != nil {
+				log.Warn().Err(markErr).Msgf("Failed clearing failed deployment marker of cloud function %v", params.App)
+			}
+		}
+		return
+	}
+	if params.SnapshotBucket != "" {
+		if markErr := markDeploymentFailed(ctx, params, function); markErr != nil {
+			log.Warn().Err(markErr).Msgf("Failed marking failed deployment of cloud function %v", params.App)
+		}
+	}
+	if captured == nil {
+		log.Fatal().Err(err).Msgf("Verification of cloud function %v failed", params.App)
+	}
+
+	rollbackAfterFailedVerification(ctx, params, credential, captured, err)
+}
+
+// getDeployLabels returns the estafette labels with the build version, so the snapshot of the function can be stored under that version when deploying over it, and the preview labels
+func getDeployLabels(params Params, labels map[string]string, now time.Time) map[string]string {
+
+	deployLabels := map[string]string{}
+	for k, v := range labels {
+		deployLabels[k] = v
+	}
+	if params.BuildVersion != "" {
+		deployLabels[buildVersionLabel] = sanitizeLabel(params.BuildVersion)
+	}
+	if params.Preview != nil {
+		for k, v := range getPreviewLabels(params.Preview, now) {
+			deployLabels[k] = v
+		}
+	}
+
+	return deployLabels
+}
+
+// runDeploy deploys the function and returns it as described after deploying, or nil for a dry run; with a recreation the function is deleted only after all checks passed, right before deploying it again. It only returns an error if deploying or describing fails, so an automatic rollback can still report it
+func runDeploy(ctx context.Context, params Params, credential GKECredentials, recreation *Recreation, labelParams []string, extraArguments []string) (*CloudFunction, error) {
+
+	envVarsFilePath := ""
+	if params.EnvironmentMode == "replace" && len(params.EnvironmentVariables) > 0 {
+
+		// pass environment variables as file to avoid having to escape commas, equal signs and newlines in values
+		var err error
+		envVarsFilePath, err = writeEnvVarsFile(params.EnvironmentVariables)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed writing environment variables file")
+		}
+		defer os.Remove(envVarsFilePath)
+	}
+
+	arguments, err := getDeployArguments(params, credential, labelParams, envVarsFilePath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed preparing deploy arguments")
+	}
+	arguments = append(arguments, extraArguments...)
+
+	if params.DryRun {
+		log.Info().Msgf("Dry run cloud function %v deployment...", params.App)
+		log.Info().Msgf("gcloud %v", arguments)
+		return nil, nil
+	}
+
+	if params.Trigger == "topic" && params.CreateTopic {
+		ensureTopic(ctx, credential.AdditionalProperties.Project, params.TriggerValue, labelParams)
+	}
+
+	if recreation != nil {
+		deleteForRecreation(ctx, params, credential, recreation)
+	}
+
+	log.Info().Msgf("Deploying cloud function %v...", params.App)
+	err = foundation.RunCommandWithArgsExtended(ctx, "gcloud", arguments)
+	if err != nil {
+		return nil, fmt.Errorf("Failed deploying cloud function %v: %v", params.App, err)
+	}
+
+	// gcloud functions deploy (NAME : --region=REGION)
+	// [--entry-point=ENTRY_POINT] [--memory=MEMORY] [--retry]
+	// [--runtime=RUNTIME] [--service-account=SERVICE_ACCOUNT]
+	// [--source=SOURCE] [--stage-bucket=STAGE_BUCKET] [--timeout=TIMEOUT]
+	// [--update-labels=[KEY=VALUE,...]]
+	// [--clear-env-vars | --env-vars-file=FILE_PATH
+	//   | --set-env-vars=[KEY=VALUE,...]
+	//   | --remove-env-vars=[KEY,...] --update-env-vars=[KEY=VALUE,...]]
+	// [--clear-labels | --remove-labels=[KEY,...]]
+	// [--trigger-bucket=TRIGGER_BUCKET | --trigger-http
+	//   | --trigger-topic=TRIGGER_TOPIC
+	//   | --trigger-event=EVENT_TYPE --trigger-resource=RESOURCE]
+	// [GCLOUD_WIDE_FLAG ...]
+
+	// NAME
+	//     gcloud functions deploy - create or update a Google Cloud Function
+
+	// SYNOPSIS
+	//     gcloud functions deploy (NAME : --region=REGION)
+	//         [--entry-point=ENTRY_POINT] [--memory=MEMORY] [--retry]
+	//         [--runtime=RUNTIME] [--service-account=SERVICE_ACCOUNT]
+	//         [--source=SOURCE] [--stage-bucket=STAGE_BUCKET] [--timeout=TIMEOUT]
+	//         [--update-labels=[KEY=VALUE,...]]
+	//         [--clear-env-vars | --env-vars-file=FILE_PATH
+	//           | --set-env-vars=[KEY=VALUE,...]
+	//           | --remove-env-vars=[KEY,...] --update-env-vars=[KEY=VALUE,...]]
+	//         [--clear-labels | --remove-labels=[KEY,...]]
+	//         [--trigger-bucket=TRIGGER_BUCKET | --trigger-http
+	//           | --trigger-topic=TRIGGER_TOPIC
+	//           | --trigger-event=EVENT_TYPE --trigger-resource=RESOURCE]
+	//         [GCLOUD_WIDE_FLAG ...]
+
+	// DESCRIPTION
+	//     Create or update a Google Cloud Function.
+
+	// POSITIONAL ARGUMENTS
+	// 		Function resource - The Cloud function name to deploy. The arguments in
+	// 		this group can be used to specify the attributes of this resource. (NOTE)
+	// 		Some attributes are not given arguments in this group but can be set in
+	// 		other ways. To set the [project] attribute: provide the argument [NAME] on
+	// 		the command line with a fully specified name; provide the argument
+	// 		[--project] on the command line; set the property [core/project]. This
+	// 		must be specified.
+
+	// 			NAME
+	// 				 ID of the function or fully qualified identifier for the function.
+	// 				 This positional must be specified if any of the other arguments in
+	// 				 this group are specified.
+
+	// 			--region=REGION
+	// 				 The Cloud region for the function. Overrides the default
+	// 				 functions/region property value for this command invocation.
+
+	// FLAGS
+	// 		--entry-point=ENTRY_POINT
+	// 			 Name of a Google Cloud Function (as defined in source code) that will
+	// 			 be executed. Defaults to the resource name suffix, if not specified.
+	// 			 For backward compatibility, if function with given name is not found,
+	// 			 then the system will try to use function named "function". For Node.js
+	// 			 this is name of a function exported by the module specified in
+	// 			 source_location.
+
+	// 		--memory=MEMORY
+	// 			 Limit on the amount of memory the function can use.
+
+	// 			 Allowed values are: 128MB, 256MB, 512MB, 1024MB, and 2048MB. By
+	// 			 default, a new function is limited to 256MB of memory. When deploying
+	// 			 an update to an existing function, the function will keep its old
+	// 			 memory limit unless you specify this flag.
+
+	// 		--retry
+	// 			 If specified, then the function will be retried in case of a failure.
+
+	// 		--runtime=RUNTIME
+	// 			 Runtime in which to run the function.
+
+	// 			 Required when deploying a new function; optional when updating an
+	// 			 existing function.
+
+	// 			 Choices:
+
+	// 			 ◆ nodejs8: Node.js 8
+	// 			 ◆ nodejs10: Node.js 10
+	// 			 ◆ python37: Python 3.7
+	// 			 ◆ go111: Go 1.11
+	// 			 ◆ nodejs6: Node.js 6 (deprecated)
+
+	// 		--service-account=SERVICE_ACCOUNT
+	// 			 The email address of the IAM service account associated with the
+	// 			 function at runtime. The service account represents the identity of the
+	// 			 running function, and determines what permissions the function has.
+
+	// 			 If not provided, the function will use the project's default service
+	// 			 account.
+
+	// 		--source=SOURCE
+	// 			 Location of source code to deploy.
+
+	// 			 Location of the source can be one of the following three options:
+
+	// 			 ◆ Source code in Google Cloud Storage (must be a .zip archive),
+	// 			 ◆ Reference to source repository or,
+	// 			 ◆ Local filesystem path (root directory of function source).
+
+	// 	 Note that if you do not specify the --source flag:
+
+	// 		 ▪ Current directory will be used for new function deployments.
+	// 		 ▪ If the function is previously deployed using a local filesystem path,
+	// 	 then function's source code will be updated using the current directory.
+	// 		 ▪ If the function is previously deployed using a Google Cloud Storage
+	// 	 location or a source repository, then the function's source code will not
+	// 	 be updated.
+
+	// 	 The value of the flag will be interpreted as a Cloud Storage location, if
+	// 	 it starts with gs://.
+
+	// 	 The value will be interpreted as a reference to a source repository, if it
+	// 	 starts with https://.
+
+	// 	 Otherwise, it will be interpreted as the local filesystem path. When
+	// 	 deploying source from the local filesystem, this command skips files
+	// 	 specified in the .gcloudignore file (see gcloud topic gcloudignore for more
+	// 	 information). If the .gcloudignore file doesn't exist, the command will try
+	// 	 to create it.
+
+	// 	 The minimal source repository URL is:
+	// 	 https://source.developers.google.com/projects/${PROJECT}/repos/${REPO}
+
+	// 	 By using the URL above, sources from the root directory of the repository
+	// 	 on the revision tagged master will be used.
+
+	// 	 If you want to deploy from a revision different from master, append one of
+	// 	 the following three sources to the URL:
+
+	// 		 ▪ /revisions/${REVISION},
+	// 		 ▪ /moveable-aliases/${MOVEABLE_ALIAS},
+	// 		 ▪ /fixed-aliases/${FIXED_ALIAS}.
+
+	// 	 If you'd like to deploy sources from a directory different from the root,
+	// 	 you must specify a revision, a moveable alias, or a fixed alias, as above,
+	// 	 and append /paths/${PATH_TO_SOURCES_DIRECTORY} to the URL.
+
+	// 	 Overall, the URL should match the following regular expression:
+
+	// 			 ^https://source\.developers\.google\.com/projects/
+	// 			 (?<accountId>[^/]+)/repos/(?<repoName>[^/]+)
+	// 			 (((/revisions/(?<commit>[^/]+))|(/moveable-aliases/(?<branch>[^/]+))|
+	// 			 (/fixed-aliases/(?<tag>[^/]+)))(/paths/(?<path>.*))?)?$
+
+	// 	 An example of a validly formatted source repository URL is:
+
+	// 			 https://source.developers.google.com/projects/123456789/repos/testrepo/
+	// 			 moveable-aliases/alternate-branch/paths/path-to=source
+
+	// 		--stage-bucket=STAGE_BUCKET
+	// 			 When deploying a function from a local directory, this flag's value is
+	// 			 the name of the Google Cloud Storage bucket in which source code will
+	// 			 be stored. Note that if you set the --stage-bucket flag when deploying
+	// 			 a function, you will need to specify --source or --stage-bucket in
+	// 			 subsequent deployments to update your source code. To use this flag
+	// 			 successfully, the account in use must have permissions to write to this
+	// 			 bucket. For help granting access, refer to this guide:
+	// 			 https://cloud.google.com/storage/docs/access-control/
+
+	// 		--timeout=TIMEOUT
+	// 			 The function execution timeout, e.g. 30s for 30 seconds. Defaults to
+	// 			 original value for existing function or 60 seconds for new functions.
+	// 			 Cannot be more than 540s. See $ gcloud topic datetimes for information
+	// 			 on duration formats.
+
+	// 		--update-labels=[KEY=VALUE,...]
+	// 			 List of label KEY=VALUE pairs to update. If a label exists its value is
+	// 			 modified, otherwise a new label is created.
+
+	// 			 Keys must start with a lowercase character and contain only hyphens
+	// 			 (-), underscores (_), lowercase characters, and numbers. Values must
+	// 			 contain only hyphens (-), underscores (_), lowercase characters, and
+	// 			 numbers.
+
+	// 			 Label keys starting with deployment are reserved for use by deployment
+	// 			 tools and cannot be specified manually.
+
+	// 		At most one of these may be specified:
+
+	// 			--clear-env-vars
+	// 				 Remove all environment variables.
+
+	// 			--env-vars-file=FILE_PATH
+	// 				 Path to a local YAML file with definitions for all environment
+	// 				 variables. All existing environment variables will be removed before
+	// 				 the new environment variables are added.
+
+	// 			--set-env-vars=[KEY=VALUE,...]
+	// 				 List of key-value pairs to set as environment variables. All existing
+	// 				 environment variables will be removed first.
+
+	// 			Only --update-env-vars and --remove-env-vars can be used together. If
+	// 			both are specified, --remove-env-vars will be applied first.
+
+	// 				--remove-env-vars=[KEY,...]
+	// 					 List of environment variables to be removed.
+
+	// 				--update-env-vars=[KEY=VALUE,...]
+	// 					 List of key-value pairs to set as environment variables.
+	// 					 At most one of these may be specified:
+
+	// 					 --clear-labels
+	// 							Remove all labels. If --update-labels is also specified then
+	// 							--clear-labels is applied first.
+
+	// 							For example, to remove all labels:
+
+	// 									$ gcloud functions deploy --clear-labels
+
+	// 							To set the labels to exactly "foo" and "baz":
+
+	// 									$ gcloud functions deploy --clear-labels \
+	// 										--update-labels foo=bar,baz=qux
+
+	// 					 --remove-labels=[KEY,...]
+	// 							List of label keys to remove. If a label does not exist it is
+	// 							silently ignored.Label keys starting with deployment are reserved for
+	// 							use by deployment tools and cannot be specified manually.
+
+	// 				 If you don't specify a trigger when deploying an update to an existing
+	// 				 function it will keep its current trigger. You must specify
+	// 				 --trigger-topic, --trigger-bucket, --trigger-http or (--trigger-event AND
+	// 				 --trigger-resource) when deploying a new function. At most one of these
+	// 				 may be specified:
+
+	// 					 --trigger-bucket=TRIGGER_BUCKET
+	// 							Google Cloud Storage bucket name. Every change in files in this
+	// 							bucket will trigger function execution.
+	// 							--trigger-http
+	// 							Function will be assigned an endpoint, which you can view by using
+	// 							the describe command. Any HTTP request (of a supported type) to the
+	// 							endpoint will trigger function execution. Supported HTTP request
+	// 							types are: POST, PUT, GET, DELETE, and OPTIONS.
+
+	// 					 --trigger-topic=TRIGGER_TOPIC
+	// 							Name of Pub/Sub topic. Every message published in this topic will
+	// 							trigger function execution with message contents passed as input
+	// 							data.
+
+	// 					 --trigger-event=EVENT_TYPE
+	// 							Specifies which action should trigger the function. For a list of
+	// 							acceptable values, call gcloud functions event-types list.
+
+	// 					 --trigger-resource=RESOURCE
+	// 							Specifies which resource from --trigger-event is being observed. E.g.
+	// 							if --trigger-event is
+	// 							providers/cloud.storage/eventTypes/object.change, --trigger-resource
+	// 							must be a bucket name. For a list of expected resources, call gcloud
+	// 							functions event-types list.
+
+	// 		GCLOUD WIDE FLAGS
+	// 				These flags are available to all commands: --account, --configuration,
+	// 				--flags-file, --flatten, --format, --help, --impersonate-service-account,
+	// 				--log-http, --project, --quiet, --trace-token, --user-output-enabled,
+	// 				--verbosity. Run $ gcloud help for details.
+
+	// 		NOTES
+	// 				This variant is also available:
+
+	log.Info().Msgf("Describing cloud function %v...", params.App)
+	function, output, err := describeFunction(ctx, params.App, credential.AdditionalProperties.Region, params.Generation)
+	if err != nil {
+		return nil, fmt.Errorf("Failed describing cloud function %v: %v: %v", params.App, err, output)
 	}
+	log.Info().Msg(output)
+
+	return function, nil
+}
+
+// getDeployArguments returns the arguments for gcloud functions deploy
+func getDeployArguments(params Params, credential GKECredentials, labelParams []string, envVarsFilePath string) ([]string, error) {
 
 	arguments := []string{
 		"functions",
@@ -189,6 +645,34 @@ func main() {
 		"--update-labels", strings.Join(labelParams, ","),
 		"--ingress-settings", params.IngressSettings}
 
+	if params.EntryPoint != "" {
+		arguments = append(arguments, "--entry-point", params.EntryPoint)
+	}
+
+	if params.Generation == 2 {
+		arguments = append(arguments, "--gen2")
+
+		if params.CPU != "" {
+			arguments = append(arguments, "--cpu", params.CPU)
+		}
+
+		if params.Concurrency > 0 {
+			arguments = append(arguments, "--concurrency", fmt.Sprintf("%v", params.Concurrency))
+		}
+	}
+
+	if params.MinInstances > 0 {
+		arguments = append(arguments, "--min-instances", fmt.Sprintf("%v", params.MinInstances))
+	}
+
+	if params.MaxInstances > 0 {
+		arguments = append(arguments, "--max-instances", fmt.Sprintf("%v", params.MaxInstances))
+	}
+
+	if params.RuntimeUpdatePolicy != "" {
+		arguments = append(arguments, "--runtime-update-policy", params.RuntimeUpdatePolicy)
+	}
+
 	if params.EgressSettings != "private-ranges-only" {
 		arguments = append(arguments, []string{"--egress-settings", params.EgressSettings}...)
 	}
@@ -197,24 +681,72 @@ func main() {
 		arguments = append(arguments, []string{"--vpc-connector", params.VPCConnector}...)
 	}
 
-	if len(params.EnvironmentVariables) > 0 {
+	switch params.EnvironmentMode {
+	case "clear":
+		arguments = append(arguments, "--clear-env-vars")
+	case "merge":
+		if len(params.RemoveEnvironment) > 0 {
+			arguments = append(arguments, "--remove-env-vars", strings.Join(params.RemoveEnvironment, ","))
+		}
+		if len(params.EnvironmentVariables) > 0 {
+			envVarUpdateArgument, err := getEnvVarsArgument(params.EnvironmentVariables)
+			if err != nil {
+				return nil, fmt.Errorf("Failed preparing environment variables: %v", err)
+			}
+			arguments = append(arguments, "--update-env-vars", envVarUpdateArgument)
+		}
+	default:
+		if envVarsFilePath != "" {
+			arguments = append(arguments, "--env-vars-file", envVarsFilePath)
+		}
+	}
 
-		// prepare to pass environment variables as argument
-		envvarParams := []string{}
-		for k, v := range params.EnvironmentVariables {
-			envvarParams = append(envvarParams, fmt.Sprintf("%v=%v", k, v))
+	if len(params.BuildEnvironmentVariables) > 0 {
+		buildEnvVarsArgument, err := getEnvVarsArgument(params.BuildEnvironmentVariables)
+		if err != nil {
+			return nil, fmt.Errorf("Failed preparing build environment variables: %v", err)
 		}
+		arguments = append(arguments, "--set-build-env-vars", buildEnvVarsArgument)
+	}
 
-		arguments = append(arguments, "--set-env-vars", strings.Join(envvarParams, ","))
+	if params.BuildWorkerPool != "" {
+		arguments = append(arguments, "--build-worker-pool", params.BuildWorkerPool)
+	}
+
+	if params.BuildServiceAccount != "" {
+		arguments = append(arguments, "--build-service-account", params.BuildServiceAccount)
+	}
+
+	if len(params.Secrets) > 0 {
+		arguments = append(arguments, "--set-secrets", getSecretArguments(params.Secrets))
 	}
 
 	if params.ServiceAccount != "" {
 		arguments = append(arguments, "--service-account", params.ServiceAccount)
 	}
 
-	if params.Trigger == "bucket" {
+	switch params.Trigger {
+	case "bucket":
 		arguments = append(arguments, "--trigger-bucket", params.TriggerValue)
-	} else {
+	case "topic":
+		arguments = append(arguments, "--trigger-topic", params.TriggerValue)
+	case "event":
+		arguments = append(arguments, "--trigger-event", params.TriggerEvent, "--trigger-resource", params.TriggerResource)
+	case "eventarc":
+		arguments = append(arguments, "--trigger-event-filters", getEventFilterArguments(params.EventFilters))
+		if len(params.EventFiltersPathPattern) > 0 {
+			arguments = append(arguments, "--trigger-event-filters-path-pattern", getEventFilterArguments(params.EventFiltersPathPattern))
+		}
+		if params.TriggerLocation != "" {
+			arguments = append(arguments, "--trigger-location", params.TriggerLocation)
+		}
+		if params.TriggerServiceAccount != "" {
+			arguments = append(arguments, "--trigger-service-account", params.TriggerServiceAccount)
+		}
+		if params.TriggerChannel != "" {
+			arguments = append(arguments, "--trigger-channel", params.TriggerChannel)
+		}
+	default:
 		arguments = append(arguments, "--trigger-http")
 	}
 
@@ -222,289 +754,114 @@ func main() {
 		arguments = append(arguments, "--allow-unauthenticated")
 	}
 
-	if params.DryRun {
-
-		log.Info().Msgf("Dry run cloud function %v deployment...", params.App)
-		log.Info().Msgf("gcloud %v", arguments)
-
-	} else {
-
-		log.Info().Msgf("Deploying cloud function %v...", params.App)
-		foundation.RunCommandWithArgs(ctx, "gcloud", arguments)
-
-		// gcloud functions deploy (NAME : --region=REGION)
-		// [--entry-point=ENTRY_POINT] [--memory=MEMORY] [--retry]
-		// [--runtime=RUNTIME] [--service-account=SERVICE_ACCOUNT]
-		// [--source=SOURCE] [--stage-bucket=STAGE_BUCKET] [--timeout=TIMEOUT]
-		// [--update-labels=[KEY=VALUE,...]]
-		// [--clear-env-vars | --env-vars-file=FILE_PATH
-		//   | --set-env-vars=[KEY=VALUE,...]
-		//   | --remove-env-vars=[KEY,...] --update-env-vars=[KEY=VALUE,...]]
-		// [--clear-labels | --remove-labels=[KEY,...]]
-		// [--trigger-bucket=TRIGGER_BUCKET | --trigger-http
-		//   | --trigger-topic=TRIGGER_TOPIC
-		//   | --trigger-event=EVENT_TYPE --trigger-resource=RESOURCE]
-		// [GCLOUD_WIDE_FLAG ...]
-
-		// NAME
-		//     gcloud functions deploy - create or update a Google Cloud Function
-
-		// SYNOPSIS
-		//     gcloud functions deploy (NAME : --region=REGION)
-		//         [--entry-point=ENTRY_POINT] [--memory=MEMORY] [--retry]
-		//         [--runtime=RUNTIME] [--service-account=SERVICE_ACCOUNT]
-		//         [--source=SOURCE] [--stage-bucket=STAGE_BUCKET] [--timeout=TIMEOUT]
-		//         [--update-labels=[KEY=VALUE,...]]
-		//         [--clear-env-vars | --env-vars-file=FILE_PATH
-		//           | --set-env-vars=[KEY=VALUE,...]
-		//           | --remove-env-vars=[KEY,...] --update-env-vars=[KEY=VALUE,...]]
-		//         [--clear-labels | --remove-labels=[KEY,...]]
-		//         [--trigger-bucket=TRIGGER_BUCKET | --trigger-http
-		//           | --trigger-topic=TRIGGER_TOPIC
-		//           | --trigger-event=EVENT_TYPE --trigger-resource=RESOURCE]
-		//         [GCLOUD_WIDE_FLAG ...]
-
-		// DESCRIPTION
-		//     Create or update a Google Cloud Function.
-
-		// POSITIONAL ARGUMENTS
-		// 		Function resource - The Cloud function name to deploy. The arguments in
-		// 		this group can be used to specify the attributes of this resource. (NOTE)
-		// 		Some attributes are not given arguments in this group but can be set in
-		// 		other ways. To set the [project] attribute: provide the argument [NAME] on
-		// 		the command line with a fully specified name; provide the argument
-		// 		[--project] on the command line; set the property [core/project]. This
-		// 		must be specified.
-
-		// 			NAME
-		// 				 ID of the function or fully qualified identifier for the function.
-		// 				 This positional must be specified if any of the other arguments in
-		// 				 this group are specified.
-
-		// 			--region=REGION
-		// 				 The Cloud region for the function. Overrides the default
-		// 				 functions/region property value for this command invocation.
-
-		// FLAGS
-		// 		--entry-point=ENTRY_POINT
-		// 			 Name of a Google Cloud Function (as defined in source code) that will
-		// 			 be executed. Defaults to the resource name suffix, if not specified.
-		// 			 For backward compatibility, if function with given name is not found,
-		// 			 then the system will try to use function named "function". For Node.js
-		// 			 this is name of a function exported by the module specified in
-		// 			 source_location.
-
-		// 		--memory=MEMORY
-		// 			 Limit on the amount of memory the function can use.
-
-		// 			 Allowed values are: 128MB, 256MB, 512MB, 1024MB, and 2048MB. By
-		// 			 default, a new function is limited to 256MB of memory. When deploying
-		// 			 an update to an existing function, the function will keep its old
-		// 			 memory limit unless you specify this flag.
-
-		// 		--retry
-		// 			 If specified, then the function will be retried in case of a failure.
-
-		// 		--runtime=RUNTIME
-		// 			 Runtime in which to run the function.
-
-		// 			 Required when deploying a new function; optional when updating an
-		// 			 existing function.
-
-		// 			 Choices:
-
-		// 			 ◆ nodejs8: Node.js 8
-		// 			 ◆ nodejs10: Node.js 10
-		// 			 ◆ python37: Python 3.7
-		// 			 ◆ go111: Go 1.11
-		// 			 ◆ nodejs6: Node.js 6 (deprecated)
-
-		// 		--service-account=SERVICE_ACCOUNT
-		// 			 The email address of the IAM service account associated with the
-		// 			 function at runtime. The service account represents the identity of the
-		// 			 running function, and determines what permissions the function has.
-
-		// 			 If not provided, the function will use the project's default service
-		// 			 account.
-
-		// 		--source=SOURCE
-		// 			 Location of source code to deploy.
-
-		// 			 Location of the source can be one of the following three options:
-
-		// 			 ◆ Source code in Google Cloud Storage (must be a .zip archive),
-		// 			 ◆ Reference to source repository or,
-		// 			 ◆ Local filesystem path (root directory of function source).
-
-		// 	 Note that if you do not specify the --source flag:
-
-		// 		 ▪ Current directory will be used for new function deployments.
-		// 		 ▪ If the function is previously deployed using a local filesystem path,
-		// 	 then function's source code will be updated using the current directory.
-		// 		 ▪ If the function is previously deployed using a Google Cloud Storage
-		// 	 location or a source repository, then the function's source code will not
-		// 	 be updated.
-
-		// 	 The value of the flag will be interpreted as a Cloud Storage location, if
-		// 	 it starts with gs://.
-
-		// 	 The value will be interpreted as a reference to a source repository, if it
-		// 	 starts with https://.
-
-		// 	 Otherwise, it will be interpreted as the local filesystem path. When
-		// 	 deploying source from the local filesystem, this command skips files
-		// 	 specified in the .gcloudignore file (see gcloud topic gcloudignore for more
-		// 	 information). If the .gcloudignore file doesn't exist, the command will try
-		// 	 to create it.
-
-		// 	 The minimal source repository URL is:
-		// 	 https://source.developers.google.com/projects/${PROJECT}/repos/${REPO}
-
-		// 	 By using the URL above, sources from the root directory of the repository
-		// 	 on the revision tagged master will be used.
-
-		// 	 If you want to deploy from a revision different from master, append one of
-		// 	 the following three sources to the URL:
-
-		// 		 ▪ /revisions/${REVISION},
-		// 		 ▪ /moveable-aliases/${MOVEABLE_ALIAS},
-		// 		 ▪ /fixed-aliases/${FIXED_ALIAS}.
-
-		// 	 If you'd like to deploy sources from a directory different from the root,
-		// 	 you must specify a revision, a moveable alias, or a fixed alias, as above,
-		// 	 and append /paths/${PATH_TO_SOURCES_DIRECTORY} to the URL.
-
-		// 	 Overall, the URL should match the following regular expression:
-
-		// 			 ^https://source\.developers\.google\.com/projects/
-		// 			 (?<accountId>[^/]+)/repos/(?<repoName>[^/]+)
-		// 			 (((/revisions/(?<commit>[^/]+))|(/moveable-aliases/(?<branch>[^/]+))|
-		// 			 (/fixed-aliases/(?<tag>[^/]+)))(/paths/(?<path>.*))?)?$
-
-		// 	 An example of a validly formatted source repository URL is:
-
-		// 			 https://source.developers.google.com/projects/123456789/repos/testrepo/
-		// 			 moveable-aliases/alternate-branch/paths/path-to=source
-
-		// 		--stage-bucket=STAGE_BUCKET
-		// 			 When deploying a function from a local directory, this flag's value is
-		// 			 the name of the Google Cloud Storage bucket in which source code will
-		// 			 be stored. Note that if you set the --stage-bucket flag when deploying
-		// 			 a function, you will need to specify --source or --stage-bucket in
-		// 			 subsequent deployments to update your source code. To use this flag
-		// 			 successfully, the account in use must have permissions to write to this
-		// 			 bucket. For help granting access, refer to this guide:
-		// 			 https://cloud.google.com/storage/docs/access-control/
-
-		// 		--timeout=TIMEOUT
-		// 			 The function execution timeout, e.g. 30s for 30 seconds. Defaults to
-		// 			 original value for existing function or 60 seconds for new functions.
-		// 			 Cannot be more than 540s. See $ gcloud topic datetimes for information
-		// 			 on duration formats.
-
-		// 		--update-labels=[KEY=VALUE,...]
-		// 			 List of label KEY=VALUE pairs to update. If a label exists its value is
-		// 			 modified, otherwise a new label is created.
-
-		// 			 Keys must start with a lowercase character and contain only hyphens
-		// 			 (-), underscores (_), lowercase characters, and numbers. Values must
-		// 			 contain only hyphens (-), underscores (_), lowercase characters, and
-		// 			 numbers.
-
-		// 			 Label keys starting with deployment are reserved for use by deployment
-		// 			 tools and cannot be specified manually.
-
-		// 		At most one of these may be specified:
+	return arguments, nil
+}
 
-		// 			--clear-env-vars
-		// 				 Remove all environment variables.
+// getLabelParams returns the labels as key=value pairs in a stable order
+func getLabelParams(labels map[string]string) []string {
+	labelParams := []string{}
+	for _, k := range sortedKeys(labels) {
+		labelParams = append(labelParams, fmt.Sprintf("%v=%v", k, labels[k]))
+	}
+	return labelParams
+}
 
-		// 			--env-vars-file=FILE_PATH
-		// 				 Path to a local YAML file with definitions for all environment
-		// 				 variables. All existing environment variables will be removed before
-		// 				 the new environment variables are added.
+// deleteFunction tears down the function and the scheduler job and topic it manages
+func deleteFunction(ctx context.Context, params Params, credential GKECredentials, labels map[string]string) {
 
-		// 			--set-env-vars=[KEY=VALUE,...]
-		// 				 List of key-value pairs to set as environment variables. All existing
-		// 				 environment variables will be removed first.
+	region := credential.AdditionalProperties.Region
+	project := credential.AdditionalProperties.Project
 
-		// 			Only --update-env-vars and --remove-env-vars can be used together. If
-		// 			both are specified, --remove-env-vars will be applied first.
+	guardOwnership(ctx, params, credential, labels)
 
-		// 				--remove-env-vars=[KEY,...]
-		// 					 List of environment variables to be removed.
+	arguments := []string{
+		"functions",
+		"delete", params.App,
+		"--region", region,
+		"--quiet"}
 
-		// 				--update-env-vars=[KEY=VALUE,...]
-		// 					 List of key-value pairs to set as environment variables.
-		// 					 At most one of these may be specified:
+	if params.Generation == 2 {
+		arguments = append(arguments, "--gen2")
+	}
 
-		// 					 --clear-labels
-		// 							Remove all labels. If --update-labels is also specified then
-		// 							--clear-labels is applied first.
+	if params.DryRun {
+		log.Info().Msgf("Dry run cloud function %v deletion...", params.App)
+		log.Info().Msgf("gcloud %v", arguments)
+		return
+	}
 
-		// 							For example, to remove all labels:
+	if job := describeSchedulerJob(ctx, params.App, region); job != nil && job.IsManaged() {
+		log.Info().Msgf("Deleting scheduler job %v...", params.App)
+		deleteSchedulerJob(ctx, params.App, region)
+	}
 
-		// 									$ gcloud functions deploy --clear-labels
+	log.Info().Msgf("Deleting cloud function %v...", params.App)
+	foundation.RunCommandWithArgs(ctx, "gcloud", arguments)
 
-		// 							To set the labels to exactly "foo" and "baz":
+	if params.Trigger == "topic" && params.CreateTopic {
+		if isTopicManaged(ctx, project, params.TriggerValue, labels) {
+			log.Info().Msgf("Deleting topic %v in project %v...", params.TriggerValue, project)
+			foundation.RunCommandWithArgs(ctx, "gcloud", []string{"pubsub", "topics", "delete", params.TriggerValue, "--project", project, "--quiet"})
+		} else {
+			log.Info().Msgf("Keeping topic %v in project %v, since it wasn't created for this app", params.TriggerValue, project)
+		}
+	}
+}
 
-		// 									$ gcloud functions deploy --clear-labels \
-		// 										--update-labels foo=bar,baz=qux
+// describeFunctionStatus prints the deployed function and its scheduler job without changing anything
+func describeFunctionStatus(ctx context.Context, params Params, credential GKECredentials) {
 
-		// 					 --remove-labels=[KEY,...]
-		// 							List of label keys to remove. If a label does not exist it is
-		// 							silently ignored.Label keys starting with deployment are reserved for
-		// 							use by deployment tools and cannot be specified manually.
+	region := credential.AdditionalProperties.Region
 
-		// 				 If you don't specify a trigger when deploying an update to an existing
-		// 				 function it will keep its current trigger. You must specify
-		// 				 --trigger-topic, --trigger-bucket, --trigger-http or (--trigger-event AND
-		// 				 --trigger-resource) when deploying a new function. At most one of these
-		// 				 may be specified:
+	log.Info().Msgf("Describing cloud function %v...", params.App)
+	function, output, err := describeFunction(ctx, params.App, region, params.Generation)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed describing cloud function %v: %v", params.App, output)
+	}
+	log.Info().Msg(output)
 
-		// 					 --trigger-bucket=TRIGGER_BUCKET
-		// 							Google Cloud Storage bucket name. Every change in files in this
-		// 							bucket will trigger function execution.
-		// 							--trigger-http
-		// 							Function will be assigned an endpoint, which you can view by using
-		// 							the describe command. Any HTTP request (of a supported type) to the
-		// 							endpoint will trigger function execution. Supported HTTP request
-		// 							types are: POST, PUT, GET, DELETE, and OPTIONS.
+	if url := function.GetURL(); url != "" {
+		log.Info().Msgf("Cloud function %v is available at %v", params.App, url)
+	}
 
-		// 					 --trigger-topic=TRIGGER_TOPIC
-		// 							Name of Pub/Sub topic. Every message published in this topic will
-		// 							trigger function execution with message contents passed as input
-		// 							data.
+	if job := describeSchedulerJob(ctx, params.App, region); job != nil && job.IsManaged() {
+		log.Info().Msgf("Describing scheduler job %v...", params.App)
+		foundation.RunCommandWithArgs(ctx, "gcloud", []string{"scheduler", "jobs", "describe", params.App, "--location", region})
+	}
+}
 
-		// 					 --trigger-event=EVENT_TYPE
-		// 							Specifies which action should trigger the function. For a list of
-		// 							acceptable values, call gcloud functions event-types list.
+// ensureTopic creates the pub/sub topic in the project if it doesn't exist yet
+func ensureTopic(ctx context.Context, project, topic string, labelParams []string) {
 
-		// 					 --trigger-resource=RESOURCE
-		// 							Specifies which resource from --trigger-event is being observed. E.g.
-		// 							if --trigger-event is
-		// 							providers/cloud.storage/eventTypes/object.change, --trigger-resource
-		// 							must be a bucket name. For a list of expected resources, call gcloud
-		// 							functions event-types list.
-
-		// 		GCLOUD WIDE FLAGS
-		// 				These flags are available to all commands: --account, --configuration,
-		// 				--flags-file, --flatten, --format, --help, --impersonate-service-account,
-		// 				--log-http, --project, --quiet, --trace-token, --user-output-enabled,
-		// 				--verbosity. Run $ gcloud help for details.
+	log.Info().Msgf("Checking if topic %v exists in project %v...", topic, project)
+	err := foundation.RunCommandWithArgsExtended(ctx, "gcloud", []string{"pubsub", "topics", "describe", topic, "--project", project})
+	if err == nil {
+		log.Info().Msgf("Topic %v already exists", topic)
+		return
+	}
 
-		// 		NOTES
-		// 				This variant is also available:
+	log.Info().Msgf("Creating topic %v in project %v...", topic, project)
+	foundation.RunCommandWithArgs(ctx, "gcloud", []string{"pubsub", "topics", "create", topic, "--project", project, "--labels", strings.Join(labelParams, ",")})
+}
 
-		log.Info().Msgf("Describing cloud function %v...", params.App)
+// isTopicManaged returns true if the topic carries the app label of this app, which ensureTopic sets when creating it
+func isTopicManaged(ctx context.Context, project, topic string, labels map[string]string) bool {
 
-		describeArguments := []string{
-			"functions",
-			"describe", params.App,
-			"--region", credential.AdditionalProperties.Region}
+	output, err := getCommandWithArgsOutput(ctx, "gcloud", []string{"pubsub", "topics", "describe", topic, "--project", project, "--format", "json"})
+	if err != nil {
+		return false
+	}
 
-		foundation.RunCommandWithArgs(ctx, "gcloud", describeArguments)
+	var topicDescription struct {
+		Labels map[string]string `json:"labels"`
 	}
+	err = json.Unmarshal([]byte(output), &topicDescription)
+	if err != nil {
+		return false
+	}
+
+	app, ok := labels["app"]
+	return ok && app != "" && topicDescription.Labels["app"] == app
 }
 
 // a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')
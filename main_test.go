@@ -0,0 +1,306 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getArgumentValue returns the value following the flag in the arguments, or an empty string if the flag isn't there
+func getArgumentValue(arguments []string, flag string) string {
+	for i, argument := range arguments {
+		if argument == flag && i+1 < len(arguments) {
+			return arguments[i+1]
+		}
+	}
+	return ""
+}
+
+func TestGetDeployArguments(t *testing.T) {
+	t.Run("ReturnsArgumentsForHttpFunction", func(t *testing.T) {
+
+		params := validParams
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{"app=myfunction"}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"functions",
+			"deploy", "myfunction",
+			"--region", "europe-west1",
+			"--memory", "256MB",
+			"--source", ".",
+			"--timeout", "60s",
+			"--runtime", "go111",
+			"--update-labels", "app=myfunction",
+			"--ingress-settings", "all",
+			"--trigger-http",
+		}, arguments)
+	})
+
+	t.Run("ReturnsTriggerTopicForTopicTrigger", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "topic"
+		params.TriggerValue = "mytopic"
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "mytopic", getArgumentValue(arguments, "--trigger-topic"))
+		assert.NotContains(t, arguments, "--trigger-http")
+	})
+
+	t.Run("ReturnsTriggerBucketForBucketTrigger", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "bucket"
+		params.TriggerValue = "mybucket"
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "mybucket", getArgumentValue(arguments, "--trigger-bucket"))
+		assert.NotContains(t, arguments, "--trigger-http")
+	})
+
+	t.Run("ReturnsTriggerEventAndResourceForEventTrigger", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "event"
+		params.TriggerEvent = "providers/cloud.firestore/eventTypes/document.write"
+		params.TriggerResource = "projects/my-project/databases/(default)/documents/users/{userId}"
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "providers/cloud.firestore/eventTypes/document.write", getArgumentValue(arguments, "--trigger-event"))
+		assert.Equal(t, "projects/my-project/databases/(default)/documents/users/{userId}", getArgumentValue(arguments, "--trigger-resource"))
+	})
+
+	t.Run("ReturnsGen2ScalingArgumentsForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.CPU = "1"
+		params.Concurrency = 80
+		params.MinInstances = 1
+		params.MaxInstances = 10
+		params.RuntimeUpdatePolicy = "automatic"
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Contains(t, arguments, "--gen2")
+		assert.Equal(t, "1", getArgumentValue(arguments, "--cpu"))
+		assert.Equal(t, "80", getArgumentValue(arguments, "--concurrency"))
+		assert.Equal(t, "1", getArgumentValue(arguments, "--min-instances"))
+		assert.Equal(t, "10", getArgumentValue(arguments, "--max-instances"))
+		assert.Equal(t, "automatic", getArgumentValue(arguments, "--runtime-update-policy"))
+	})
+
+	t.Run("OmitsGen2AndScalingArgumentsIfNotSet", func(t *testing.T) {
+
+		params := validParams
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.NotContains(t, arguments, "--gen2")
+		assert.NotContains(t, arguments, "--cpu")
+		assert.NotContains(t, arguments, "--concurrency")
+		assert.NotContains(t, arguments, "--min-instances")
+		assert.NotContains(t, arguments, "--max-instances")
+		assert.NotContains(t, arguments, "--runtime-update-policy")
+	})
+
+	t.Run("ReturnsEventarcArgumentsForEventarcTrigger", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.audit.log.v1.written", "serviceName": "storage.googleapis.com", "methodName": "storage.objects.create"}
+		params.EventFiltersPathPattern = map[string]string{"resourceName": "/projects/_/buckets/b/objects/*.txt"}
+		params.TriggerLocation = "global"
+		params.TriggerServiceAccount = "eventarc-trigger@my-project.iam.gserviceaccount.com"
+		params.TriggerChannel = "orders"
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "methodName=storage.objects.create,serviceName=storage.googleapis.com,type=google.cloud.audit.log.v1.written", getArgumentValue(arguments, "--trigger-event-filters"))
+		assert.Equal(t, "resourceName=/projects/_/buckets/b/objects/*.txt", getArgumentValue(arguments, "--trigger-event-filters-path-pattern"))
+		assert.Equal(t, "global", getArgumentValue(arguments, "--trigger-location"))
+		assert.Equal(t, "eventarc-trigger@my-project.iam.gserviceaccount.com", getArgumentValue(arguments, "--trigger-service-account"))
+		assert.Equal(t, "orders", getArgumentValue(arguments, "--trigger-channel"))
+		assert.NotContains(t, arguments, "--trigger-http")
+	})
+
+	t.Run("OmitsOptionalEventarcArgumentsIfNotSet", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.storage.object.v1.finalized", "bucket": "mybucket"}
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "bucket=mybucket,type=google.cloud.storage.object.v1.finalized", getArgumentValue(arguments, "--trigger-event-filters"))
+		assert.NotContains(t, arguments, "--trigger-event-filters-path-pattern")
+		assert.NotContains(t, arguments, "--trigger-location")
+		assert.NotContains(t, arguments, "--trigger-service-account")
+		assert.NotContains(t, arguments, "--trigger-channel")
+	})
+
+	t.Run("ReturnsSetSecretsIfSecretsAreSet", func(t *testing.T) {
+
+		params := validParams
+		params.Secrets = map[string]string{"API_KEY": "api-key", "DB_PASSWORD": "projects/my-project/secrets/db-password/versions/3"}
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "API_KEY=api-key:latest,DB_PASSWORD=projects/my-project/secrets/db-password:3", getArgumentValue(arguments, "--set-secrets"))
+	})
+
+	t.Run("OmitsSetSecretsIfSecretsAreNotSet", func(t *testing.T) {
+
+		params := validParams
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.NotContains(t, arguments, "--set-secrets")
+	})
+
+	t.Run("ReturnsEnvVarsFileForEnvironmentModeReplace", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentVariables = map[string]interface{}{"MY_VAR": "value"}
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "/tmp/env-vars.yaml")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "/tmp/env-vars.yaml", getArgumentValue(arguments, "--env-vars-file"))
+		assert.NotContains(t, arguments, "--update-env-vars")
+		assert.NotContains(t, arguments, "--clear-env-vars")
+	})
+
+	t.Run("OmitsEnvVarsFileForEnvironmentModeReplaceWithoutEnvironmentVariables", func(t *testing.T) {
+
+		params := validParams
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.NotContains(t, arguments, "--env-vars-file")
+		assert.NotContains(t, arguments, "--clear-env-vars")
+	})
+
+	t.Run("ReturnsUpdateAndRemoveEnvVarsForEnvironmentModeMerge", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentMode = "merge"
+		params.EnvironmentVariables = map[string]interface{}{"MY_VAR": "value"}
+		params.RemoveEnvironment = []string{"OLD_VAR", "OTHER_VAR"}
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "/tmp/env-vars.yaml")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "MY_VAR=value", getArgumentValue(arguments, "--update-env-vars"))
+		assert.Equal(t, "OLD_VAR,OTHER_VAR", getArgumentValue(arguments, "--remove-env-vars"))
+		assert.NotContains(t, arguments, "--env-vars-file")
+	})
+
+	t.Run("OmitsUpdateAndRemoveEnvVarsForEnvironmentModeMergeWithoutChanges", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentMode = "merge"
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.NotContains(t, arguments, "--update-env-vars")
+		assert.NotContains(t, arguments, "--remove-env-vars")
+	})
+
+	t.Run("ReturnsClearEnvVarsForEnvironmentModeClear", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentMode = "clear"
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Contains(t, arguments, "--clear-env-vars")
+		assert.NotContains(t, arguments, "--env-vars-file")
+	})
+
+	t.Run("ReturnsBuildArgumentsIfSet", func(t *testing.T) {
+
+		params := validParams
+		params.BuildEnvironmentVariables = map[string]interface{}{"GOPRIVATE": "github.com/myorg/*", "GOFLAGS": "-mod=mod"}
+		params.BuildWorkerPool = "projects/my-project/locations/europe-west1/workerPools/my-pool"
+		params.BuildServiceAccount = "projects/my-project/serviceAccounts/builder@my-project.iam.gserviceaccount.com"
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "GOFLAGS=-mod=mod,GOPRIVATE=github.com/myorg/*", getArgumentValue(arguments, "--set-build-env-vars"))
+		assert.Equal(t, "projects/my-project/locations/europe-west1/workerPools/my-pool", getArgumentValue(arguments, "--build-worker-pool"))
+		assert.Equal(t, "projects/my-project/serviceAccounts/builder@my-project.iam.gserviceaccount.com", getArgumentValue(arguments, "--build-service-account"))
+	})
+
+	t.Run("OmitsBuildArgumentsIfNotSet", func(t *testing.T) {
+
+		params := validParams
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.NotContains(t, arguments, "--set-build-env-vars")
+		assert.NotContains(t, arguments, "--build-worker-pool")
+		assert.NotContains(t, arguments, "--build-service-account")
+	})
+
+	t.Run("ReturnsEntryPointServiceAccountAndNetworkArgumentsIfSet", func(t *testing.T) {
+
+		params := validParams
+		params.EntryPoint = "HelloHTTP"
+		params.ServiceAccount = "myfunction@my-project.iam.gserviceaccount.com"
+		params.VPCConnector = "myconnector"
+		params.EgressSettings = "all"
+		params.AllowUnauthenticated = true
+
+		// act
+		arguments, err := getDeployArguments(params, validCredential, []string{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "HelloHTTP", getArgumentValue(arguments, "--entry-point"))
+		assert.Equal(t, "myfunction@my-project.iam.gserviceaccount.com", getArgumentValue(arguments, "--service-account"))
+		assert.Equal(t, "myconnector", getArgumentValue(arguments, "--vpc-connector"))
+		assert.Equal(t, "all", getArgumentValue(arguments, "--egress-settings"))
+		assert.Contains(t, arguments, "--allow-unauthenticated")
+	})
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MetricsQuery names the metrics of the function in cloud monitoring; gen1 functions report executions, gen2 functions requests to the cloud run service underneath
+type MetricsQuery struct {
+	ResourceFilter string
+	CountMetric    string
+	StatusLabel    string
+	OkStatuses     []string
+	LatencyMetric  string
+	LatencyUnit    time.Duration
+}
+
+// getMetricsQuery returns the metrics to verify for the generation of the function
+func getMetricsQuery(params Params, region string) MetricsQuery {
+	if params.Generation == 2 {
+		return MetricsQuery{
+			ResourceFilter: fmt.Sprintf(`resource.type="cloud_run_revision" AND resource.labels.service_name="%v" AND resource.labels.location="%v"`, strings.ToLower(params.App), region),
+			CountMetric:    "run.googleapis.com/request_count",
+			StatusLabel:    "response_code_class",
+			OkStatuses:     []string{"1xx", "2xx", "3xx", "4xx"},
+			LatencyMetric:  "run.googleapis.com/request_latencies",
+			LatencyUnit:    time.Millisecond,
+		}
+	}
+
+	return MetricsQuery{
+		ResourceFilter: fmt.Sprintf(`resource.type="cloud_function" AND resource.labels.function_name="%v" AND resource.labels.region="%v"`, params.App, region),
+		CountMetric:    "cloudfunctions.googleapis.com/function/execution_count",
+		StatusLabel:    "status",
+		OkStatuses:     []string{"ok"},
+		LatencyMetric:  "cloudfunctions.googleapis.com/function/execution_times",
+		LatencyUnit:    time.Nanosecond,
+	}
+}
+
+// metricsPollInterval is the time between reading the metrics during the verification window
+const metricsPollInterval = time.Minute
+
+// TimeSeriesList represents the response of the cloud monitoring timeSeries.list api
+type TimeSeriesList struct {
+	TimeSeries []struct {
+		Metric struct {
+			Labels map[string]string `json:"labels,omitempty"`
+		} `json:"metric"`
+		Points []struct {
+			Value struct {
+				Int64Value  string  `json:"int64Value,omitempty"`
+				DoubleValue float64 `json:"doubleValue,omitempty"`
+			} `json:"value"`
+		} `json:"points"`
+	} `json:"timeSeries"`
+}
+
+// FunctionMetrics summarizes the executions of a function in a time window
+type FunctionMetrics struct {
+	Executions int64
+	Errors     int64
+	P95Latency time.Duration
+}
+
+// ErrorRate returns the fraction of executions that didn't finish ok
+func (m FunctionMetrics) ErrorRate() float64 {
+	if m.Executions == 0 {
+		return 0
+	}
+	return float64(m.Errors) / float64(m.Executions)
+}
+
+// String renders the metrics as a line of the verification log
+func (m FunctionMetrics) String() string {
+	return fmt.Sprintf("%v executions, error rate %.2f%%, p95 latency %v", m.Executions, m.ErrorRate()*100, m.P95Latency)
+}
+
+// getExecutionCounts sums the executions per status; every status that isn't ok for the query counts as error
+func getExecutionCounts(list TimeSeriesList, query MetricsQuery) (executions, errors int64) {
+	for _, series := range list.TimeSeries {
+		for _, point := range series.Points {
+			count, _ := strconv.ParseInt(point.Value.Int64Value, 10, 64)
+			executions += count
+			if !inStringArray(series.Metric.Labels[query.StatusLabel], query.OkStatuses) {
+				errors += count
+			}
+		}
+	}
+	return
+}
+
+// getLatencyPercentile returns the highest percentile of the aligned execution times, which are reported in the unit of the query
+func getLatencyPercentile(list TimeSeriesList, query MetricsQuery) time.Duration {
+	percentile := 0.0
+	for _, series := range list.TimeSeries {
+		for _, point := range series.Points {
+			percentile = math.Max(percentile, point.Value.DoubleValue)
+		}
+	}
+	return time.Duration(percentile * float64(query.LatencyUnit))
+}
+
+// checkFunctionMetrics compares the metrics with the thresholds and the baseline, if there is one, and returns the regressions
+func checkFunctionMetrics(params *MetricsParam, current, baseline *FunctionMetrics) []error {
+
+	errors := []error{}
+
+	if params.MaxErrorRate != nil && current.ErrorRate() > *params.MaxErrorRate {
+		errors = append(errors, fmt.Errorf("Error rate %.2f%% exceeds maximum of %.2f%%", current.ErrorRate()*100, *params.MaxErrorRate*100))
+	}
+
+	if params.MaxP95Latency != "" {
+		maxP95Latency, _ := time.ParseDuration(params.MaxP95Latency)
+		if current.P95Latency > maxP95Latency {
+			errors = append(errors, fmt.Errorf("P95 latency %v exceeds maximum of %v", current.P95Latency, maxP95Latency))
+		}
+	}
+
+	if baseline != nil && baseline.Executions > 0 {
+		if current.ErrorRate() > baseline.ErrorRate()+params.ErrorRateMargin {
+			errors = append(errors, fmt.Errorf("Error rate %.2f%% regressed from %.2f%% before deploying", current.ErrorRate()*100, baseline.ErrorRate()*100))
+		}
+		if float64(current.P95Latency) > float64(baseline.P95Latency)*(1+params.LatencyMargin) {
+			errors = append(errors, fmt.Errorf("P95 latency %v regressed from %v before deploying", current.P95Latency, baseline.P95Latency))
+		}
+	}
+
+	return errors
+}
+
+// listTimeSeries reads the metric of the function from cloud monitoring, aligned to a single value per series over the interval
+func listTimeSeries(ctx context.Context, credential GKECredentials, query MetricsQuery, metricType, aligner, reducer string, groupByFields []string, start, end time.Time) (TimeSeriesList, error) {
+
+	var list TimeSeriesList
+
+	token, err := getAccessToken(ctx, "")
+	if err != nil {
+		return list, err
+	}
+
+	// the alignment period is whole seconds and at least a minute
+	alignmentPeriod := int64(math.Ceil(end.Sub(start).Seconds()))
+	if alignmentPeriod < 60 {
+		alignmentPeriod = 60
+	}
+
+	values := url.Values{}
+	values.Set("filter", fmt.Sprintf(`metric.type="%v" AND %v`, metricType, query.ResourceFilter))
+	values.Set("interval.startTime", start.UTC().Format(time.RFC3339))
+	values.Set("interval.endTime", end.UTC().Format(time.RFC3339))
+	values.Set("aggregation.alignmentPeriod", fmt.Sprintf("%vs", alignmentPeriod))
+	values.Set("aggregation.perSeriesAligner", aligner)
+	values.Set("aggregation.crossSeriesReducer", reducer)
+	for _, field := range groupByFields {
+		values.Add("aggregation.groupByFields", field)
+	}
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://monitoring.googleapis.com/v3/projects/%v/timeSeries?%v", credential.AdditionalProperties.Project, values.Encode()), nil)
+	if err != nil {
+		return list, err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	err = doJSONRequest(request.WithContext(ctx), &list)
+	return list, err
+}
+
+// readFunctionMetrics reads the executions by status and the p95 execution time of the function between start and end
+func readFunctionMetrics(ctx context.Context, params Params, credential GKECredentials, start, end time.Time) (*FunctionMetrics, error) {
+
+	query := getMetricsQuery(params, credential.AdditionalProperties.Region)
+
+	counts, err := listTimeSeries(ctx, credential, query, query.CountMetric, "ALIGN_SUM", "REDUCE_SUM", []string{"metric.label." + query.StatusLabel}, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	times, err := listTimeSeries(ctx, credential, query, query.LatencyMetric, "ALIGN_DELTA", "REDUCE_PERCENTILE_95", nil, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := FunctionMetrics{P95Latency: getLatencyPercentile(times, query)}
+	metrics.Executions, metrics.Errors = getExecutionCounts(counts, query)
+
+	return &metrics, nil
+}
+
+// readBaselineMetrics reads the metrics of the window before deploying to compare with after deploying; it returns nil if there's no baseline
+func readBaselineMetrics(ctx context.Context, params Params, credential GKECredentials) *FunctionMetrics {
+
+	if params.Metrics == nil || !params.Metrics.Baseline || params.DryRun {
+		return nil
+	}
+
+	window, _ := time.ParseDuration(params.Metrics.Window)
+	end := time.Now()
+
+	log.Info().Msgf("Reading metrics of cloud function %v of the last %v as baseline...", params.App, window)
+	baseline, err := readFunctionMetrics(ctx, params, credential, end.Add(-window), end)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed reading baseline metrics of cloud function %v, only comparing with the thresholds", params.App)
+		return nil
+	}
+	if baseline.Executions == 0 {
+		log.Warn().Msgf("Cloud function %v had no executions in the last %v, only comparing with the thresholds", params.App, window)
+		return nil
+	}
+	log.Info().Msgf("Baseline: %v", baseline)
+
+	return baseline
+}
+
+// runMetricsVerification polls the metrics of the function during the window after deploying; it returns an error if the error rate or latency exceed the thresholds or regress from the baseline
+func runMetricsVerification(ctx context.Context, params Params, credential GKECredentials, function *CloudFunction, baseline *FunctionMetrics) error {
+
+	window, _ := time.ParseDuration(params.Metrics.Window)
+	start := getDeployedTime(function, time.Now())
+	deadline := start.Add(window)
+
+	log.Info().Msgf("Watching metrics of cloud function %v for %v...", params.App, window)
+	var current *FunctionMetrics
+	for {
+		end := time.Now()
+		if end.After(deadline) {
+			end = deadline
+		}
+
+		metrics, err := readFunctionMetrics(ctx, params, credential, start, end)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed reading metrics of cloud function %v", params.App)
+		} else {
+			current = metrics
+			log.Info().Msgf("Metrics since deploying: %v", current)
+		}
+
+		if !end.Before(deadline) {
+			break
+		}
+		time.Sleep(metricsPollInterval)
+	}
+
+	if current == nil {
+		return fmt.Errorf("Failed reading metrics of cloud function %v during the verification window", params.App)
+	}
+	if current.Executions == 0 {
+		log.Warn().Msgf("Cloud function %v had no executions in the %v after deploying, so its metrics can't be verified", params.App, window)
+		return nil
+	}
+
+	regressions := checkFunctionMetrics(params.Metrics, current, baseline)
+	if len(regressions) > 0 {
+		return fmt.Errorf("Metrics of cloud function %v regressed after deploying: %v", params.App, regressions)
+	}
+
+	log.Info().Msgf("Metrics of cloud function %v are within bounds", params.App)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetricsParam is used to watch the error rate and latency of the function in cloud monitoring for a while after deploying it
+type MetricsParam struct {
+	Window          string   `json:"window,omitempty"`
+	MaxErrorRate    *float64 `json:"maxErrorRate,omitempty"`
+	MaxP95Latency   string   `json:"maxP95Latency,omitempty"`
+	Baseline        bool     `json:"baseline,omitempty"`
+	ErrorRateMargin float64  `json:"errorRateMargin,omitempty"`
+	LatencyMargin   float64  `json:"latencyMargin,omitempty"`
+}
+
+// SetDefaults fills in empty fields with convention-based defaults
+func (p *MetricsParam) SetDefaults() {
+
+	// default window to 10 minutes, enough executions for a high-traffic function to compare
+	if p.Window == "" {
+		p.Window = "10m"
+	}
+
+	// default margins to allow for some noise compared to the baseline; an error rate 1 percent point higher or a latency 20 percent higher
+	if p.Baseline {
+		if p.ErrorRateMargin == 0 {
+			p.ErrorRateMargin = 0.01
+		}
+		if p.LatencyMargin == 0 {
+			p.LatencyMargin = 0.2
+		}
+	}
+}
+
+// ValidateRequiredProperties checks whether all needed properties are set
+func (p *MetricsParam) ValidateRequiredProperties() (bool, []error) {
+
+	errors := []error{}
+
+	if window, err := time.ParseDuration(p.Window); err != nil || window < time.Minute {
+		errors = append(errors, fmt.Errorf("Metrics window %v is not a valid duration of at least a minute; set it to a duration like 10m", p.Window))
+	}
+
+	if p.MaxErrorRate != nil && (*p.MaxErrorRate < 0 || *p.MaxErrorRate > 1) {
+		errors = append(errors, fmt.Errorf("Metrics maxErrorRate %v is not valid; set it to a fraction between 0 and 1", *p.MaxErrorRate))
+	}
+
+	if p.MaxP95Latency != "" {
+		if latency, err := time.ParseDuration(p.MaxP95Latency); err != nil || latency <= 0 {
+			errors = append(errors, fmt.Errorf("Metrics maxP95Latency %v is not a valid duration; set it to a duration like 500ms", p.MaxP95Latency))
+		}
+	}
+
+	if p.ErrorRateMargin < 0 || p.LatencyMargin < 0 {
+		errors = append(errors, fmt.Errorf("Metrics errorRateMargin and latencyMargin can't be negative"))
+	}
+
+	if p.MaxErrorRate == nil && p.MaxP95Latency == "" && !p.Baseline {
+		errors = append(errors, fmt.Errorf("Metrics needs maxErrorRate, maxP95Latency or baseline to compare the metrics with"))
+	}
+
+	return len(errors) == 0, errors
+}
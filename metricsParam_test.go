@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsParamSetDefaults(t *testing.T) {
+	t.Run("DefaultsWindowToTenMinutes", func(t *testing.T) {
+
+		params := MetricsParam{}
+
+		// act
+		params.SetDefaults()
+
+		assert.Equal(t, "10m", params.Window)
+		assert.Equal(t, 0.0, params.ErrorRateMargin)
+		assert.Equal(t, 0.0, params.LatencyMargin)
+	})
+
+	t.Run("DefaultsMarginsIfBaselineIsTrue", func(t *testing.T) {
+
+		params := MetricsParam{Baseline: true}
+
+		// act
+		params.SetDefaults()
+
+		assert.Equal(t, 0.01, params.ErrorRateMargin)
+		assert.Equal(t, 0.2, params.LatencyMargin)
+	})
+}
+
+func TestMetricsParamValidateRequiredProperties(t *testing.T) {
+
+	maxErrorRate := 0.05
+	validMetricsParam := MetricsParam{
+		Window:        "10m",
+		MaxErrorRate:  &maxErrorRate,
+		MaxP95Latency: "500ms",
+	}
+
+	t.Run("ReturnsTrueIfAllPropertiesAreValid", func(t *testing.T) {
+
+		params := validMetricsParam
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsTrueIfOnlyBaselineIsTrue", func(t *testing.T) {
+
+		params := MetricsParam{Window: "10m", Baseline: true}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsTrueIfMaxErrorRateIsZero", func(t *testing.T) {
+
+		maxErrorRate := 0.0
+		params := MetricsParam{Window: "10m", MaxErrorRate: &maxErrorRate}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfWindowIsShorterThanAMinute", func(t *testing.T) {
+
+		params := validMetricsParam
+		params.Window = "30s"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfMaxErrorRateIsMoreThanOne", func(t *testing.T) {
+
+		params := validMetricsParam
+		maxErrorRate := 5.0
+		params.MaxErrorRate = &maxErrorRate
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfMaxP95LatencyIsNotValid", func(t *testing.T) {
+
+		params := validMetricsParam
+		params.MaxP95Latency = "500"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfThereIsNothingToCompareWith", func(t *testing.T) {
+
+		params := MetricsParam{Window: "10m"}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+}
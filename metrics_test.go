@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMetricsQuery(t *testing.T) {
+	t.Run("ReturnsCloudFunctionsMetricsForGen1", func(t *testing.T) {
+
+		params := Params{App: "my-app", Generation: 1}
+
+		// act
+		query := getMetricsQuery(params, "europe-west1")
+
+		assert.Equal(t, `resource.type="cloud_function" AND resource.labels.function_name="my-app" AND resource.labels.region="europe-west1"`, query.ResourceFilter)
+		assert.Equal(t, "cloudfunctions.googleapis.com/function/execution_count", query.CountMetric)
+		assert.Equal(t, "cloudfunctions.googleapis.com/function/execution_times", query.LatencyMetric)
+	})
+
+	t.Run("ReturnsCloudRunMetricsForGen2", func(t *testing.T) {
+
+		params := Params{App: "my-app", Generation: 2}
+
+		// act
+		query := getMetricsQuery(params, "europe-west1")
+
+		assert.Equal(t, `resource.type="cloud_run_revision" AND resource.labels.service_name="my-app" AND resource.labels.location="europe-west1"`, query.ResourceFilter)
+		assert.Equal(t, "run.googleapis.com/request_count", query.CountMetric)
+		assert.Equal(t, "run.googleapis.com/request_latencies", query.LatencyMetric)
+	})
+}
+
+func TestGetExecutionCounts(t *testing.T) {
+	t.Run("CountsExecutionsWithStatusOtherThanOkAsErrorsForGen1", func(t *testing.T) {
+
+		var list TimeSeriesList
+		err := json.Unmarshal([]byte(`{"timeSeries":[
+			{"metric":{"labels":{"status":"ok"}},"points":[{"value":{"int64Value":"950"}}]},
+			{"metric":{"labels":{"status":"error"}},"points":[{"value":{"int64Value":"30"}}]},
+			{"metric":{"labels":{"status":"timeout"}},"points":[{"value":{"int64Value":"15"}},{"value":{"int64Value":"5"}}]}
+		]}`), &list)
+		assert.Nil(t, err)
+
+		// act
+		executions, errors := getExecutionCounts(list, getMetricsQuery(Params{Generation: 1}, "europe-west1"))
+
+		assert.Equal(t, int64(1000), executions)
+		assert.Equal(t, int64(50), errors)
+	})
+
+	t.Run("CountsServerErrorsAsErrorsForGen2", func(t *testing.T) {
+
+		var list TimeSeriesList
+		err := json.Unmarshal([]byte(`{"timeSeries":[
+			{"metric":{"labels":{"response_code_class":"2xx"}},"points":[{"value":{"int64Value":"900"}}]},
+			{"metric":{"labels":{"response_code_class":"4xx"}},"points":[{"value":{"int64Value":"80"}}]},
+			{"metric":{"labels":{"response_code_class":"5xx"}},"points":[{"value":{"int64Value":"20"}}]}
+		]}`), &list)
+		assert.Nil(t, err)
+
+		// act
+		executions, errors := getExecutionCounts(list, getMetricsQuery(Params{Generation: 2}, "europe-west1"))
+
+		assert.Equal(t, int64(1000), executions)
+		assert.Equal(t, int64(20), errors)
+	})
+}
+
+func TestGetLatencyPercentile(t *testing.T) {
+	t.Run("ReturnsHighestPercentileInNanosecondsForGen1", func(t *testing.T) {
+
+		var list TimeSeriesList
+		err := json.Unmarshal([]byte(`{"timeSeries":[{"metric":{},"points":[{"value":{"doubleValue":250000000}},{"value":{"doubleValue":300000000}}]}]}`), &list)
+		assert.Nil(t, err)
+
+		// act
+		percentile := getLatencyPercentile(list, getMetricsQuery(Params{Generation: 1}, "europe-west1"))
+
+		assert.Equal(t, 300*time.Millisecond, percentile)
+	})
+
+	t.Run("ReturnsHighestPercentileInMillisecondsForGen2", func(t *testing.T) {
+
+		var list TimeSeriesList
+		err := json.Unmarshal([]byte(`{"timeSeries":[{"metric":{},"points":[{"value":{"doubleValue":300}}]}]}`), &list)
+		assert.Nil(t, err)
+
+		// act
+		percentile := getLatencyPercentile(list, getMetricsQuery(Params{Generation: 2}, "europe-west1"))
+
+		assert.Equal(t, 300*time.Millisecond, percentile)
+	})
+}
+
+func TestCheckFunctionMetrics(t *testing.T) {
+	t.Run("ReturnsNoErrorsIfMetricsAreWithinThresholds", func(t *testing.T) {
+
+		maxErrorRate := 0.05
+		params := &MetricsParam{MaxErrorRate: &maxErrorRate, MaxP95Latency: "500ms"}
+		current := &FunctionMetrics{Executions: 1000, Errors: 10, P95Latency: 300 * time.Millisecond}
+
+		// act
+		errors := checkFunctionMetrics(params, current, nil)
+
+		assert.Equal(t, 0, len(errors))
+	})
+
+	t.Run("ReturnsErrorsIfMetricsExceedThresholds", func(t *testing.T) {
+
+		maxErrorRate := 0.05
+		params := &MetricsParam{MaxErrorRate: &maxErrorRate, MaxP95Latency: "500ms"}
+		current := &FunctionMetrics{Executions: 1000, Errors: 100, P95Latency: 800 * time.Millisecond}
+
+		// act
+		errors := checkFunctionMetrics(params, current, nil)
+
+		assert.Equal(t, 2, len(errors))
+	})
+
+	t.Run("ReturnsErrorIfThereAreErrorsWithMaxErrorRateZero", func(t *testing.T) {
+
+		maxErrorRate := 0.0
+		params := &MetricsParam{MaxErrorRate: &maxErrorRate}
+		current := &FunctionMetrics{Executions: 1000, Errors: 1}
+
+		// act
+		errors := checkFunctionMetrics(params, current, nil)
+
+		assert.Equal(t, 1, len(errors))
+	})
+
+	t.Run("ReturnsNoErrorsIfMetricsAreWithinMarginsOfBaseline", func(t *testing.T) {
+
+		params := &MetricsParam{Baseline: true, ErrorRateMargin: 0.01, LatencyMargin: 0.2}
+		current := &FunctionMetrics{Executions: 1000, Errors: 25, P95Latency: 350 * time.Millisecond}
+		baseline := &FunctionMetrics{Executions: 1000, Errors: 20, P95Latency: 300 * time.Millisecond}
+
+		// act
+		errors := checkFunctionMetrics(params, current, baseline)
+
+		assert.Equal(t, 0, len(errors))
+	})
+
+	t.Run("ReturnsErrorsIfMetricsRegressFromBaseline", func(t *testing.T) {
+
+		params := &MetricsParam{Baseline: true, ErrorRateMargin: 0.01, LatencyMargin: 0.2}
+		current := &FunctionMetrics{Executions: 1000, Errors: 40, P95Latency: 400 * time.Millisecond}
+		baseline := &FunctionMetrics{Executions: 1000, Errors: 20, P95Latency: 300 * time.Millisecond}
+
+		// act
+		errors := checkFunctionMetrics(params, current, baseline)
+
+		assert.Equal(t, 2, len(errors))
+	})
+
+	t.Run("IgnoresBaselineWithoutExecutions", func(t *testing.T) {
+
+		params := &MetricsParam{Baseline: true, ErrorRateMargin: 0.01, LatencyMargin: 0.2}
+		current := &FunctionMetrics{Executions: 1000, Errors: 40, P95Latency: 400 * time.Millisecond}
+		baseline := &FunctionMetrics{}
+
+		// act
+		errors := checkFunctionMetrics(params, current, baseline)
+
+		assert.Equal(t, 0, len(errors))
+	})
+}
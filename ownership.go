@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ownershipLabels identify the pipeline a function belongs to
+var ownershipLabels = []string{"app", "team", "repo"}
+
+// checkOwnership returns an error if the function belongs to another pipeline; an ownership label set by the pipeline has to be set on the function as well, and a function without any of the ownership labels wasn't deployed by estafette and is protected the same way
+func checkOwnership(function *CloudFunction, labels map[string]string) error {
+
+	differences := []string{}
+	labeled := false
+	for _, key := range ownershipLabels {
+		value := function.Labels[key]
+		if value != "" {
+			labeled = true
+		}
+		switch {
+		case labels[key] == "" || labels[key] == value:
+		case value == "":
+			differences = append(differences, fmt.Sprintf("%v is missing instead of %v", key, labels[key]))
+		default:
+			differences = append(differences, fmt.Sprintf("%v is %v instead of %v", key, value, labels[key]))
+		}
+	}
+
+	if !labeled {
+		return fmt.Errorf("Cloud function %v has none of the labels %v, so it wasn't deployed by estafette; set takeOwnership to true to overwrite it", function.Name, strings.Join(ownershipLabels, ", "))
+	}
+	if len(differences) > 0 {
+		return fmt.Errorf("Cloud function %v is owned by another pipeline, label %v; set takeOwnership to true to overwrite it", function.Name, strings.Join(differences, ", label "))
+	}
+
+	return nil
+}
+
+// guardOwnership stops the release if the function exists and belongs to another pipeline, unless taking ownership is allowed
+func guardOwnership(ctx context.Context, params Params, credential GKECredentials, labels map[string]string) {
+
+	function, err := describeLiveFunction(ctx, params.App, credential.AdditionalProperties.Region, params.Generation)
+	if err != nil {
+		if isNotFoundError(err) {
+			// the function doesn't exist yet
+			return
+		}
+		log.Fatal().Err(err).Msgf("Failed retrieving cloud function %v to check its ownership", params.App)
+	}
+
+	err = checkOwnership(function, labels)
+	if err == nil {
+		return
+	}
+	if params.TakeOwnership {
+		log.Warn().Msgf("Taking ownership: %v", err)
+		return
+	}
+
+	log.Fatal().Err(err).Msgf("Cloud function %v belongs to another pipeline", params.App)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOwnership(t *testing.T) {
+
+	labels := map[string]string{"app": "myfunction", "team": "myteam", "repo": "myrepo"}
+
+	t.Run("ReturnsNilIfOwnershipLabelsMatch", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{"app": "myfunction", "team": "myteam", "repo": "myrepo", "language": "golang"}}
+
+		// act
+		err := checkOwnership(&function, labels)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsNilIfFunctionLacksOwnershipLabelNotSetByPipeline", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{"app": "myfunction", "team": "myteam"}}
+
+		// act
+		err := checkOwnership(&function, map[string]string{"app": "myfunction", "team": "myteam"})
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfFunctionLacksOwnershipLabelSetByPipeline", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{"app": "myfunction"}}
+
+		// act
+		err := checkOwnership(&function, labels)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfOwnershipLabelSetByPipelineIsEmptyOnFunction", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{"app": "myfunction", "team": "", "repo": "myrepo"}}
+
+		// act
+		err := checkOwnership(&function, labels)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfOwnershipLabelDiffers", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{"app": "myfunction", "team": "otherteam", "repo": "otherrepo"}}
+
+		// act
+		err := checkOwnership(&function, labels)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfFunctionHasNoOwnershipLabels", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{"deployment-tool": "console-cloud"}}
+
+		// act
+		err := checkOwnership(&function, labels)
+
+		assert.NotNil(t, err)
+	})
+}
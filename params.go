@@ -2,32 +2,98 @@ package main
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
+var bucketNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-_.]{1,220}[a-z0-9]$`)
+
 // Params is used to parameterize the deployment, set from custom properties in the manifest
 type Params struct {
 	// control params
-	DryRun bool `json:"dryrun,omitempty"`
+	Action        string `json:"action,omitempty"`
+	DryRun        bool   `json:"dryrun,omitempty"`
+	TakeOwnership bool   `json:"takeOwnership,omitempty"`
+	AllowRecreate bool   `json:"allowRecreate,omitempty"`
+	AutoRollback  bool   `json:"autoRollback,omitempty"`
 
 	// app params
 	App                  string                 `json:"app,omitempty"`
+	BuildVersion         string                 `json:"buildVersion,omitempty"`
 	Runtime              string                 `json:"runtime,omitempty"`
+	EntryPoint           string                 `json:"entryPoint,omitempty"`
 	Trigger              string                 `json:"trigger,omitempty"`
 	TriggerValue         string                 `json:"triggerValue,omitempty"`
+	CreateTopic          bool                   `json:"createTopic,omitempty"`
+	TriggerEvent         string                 `json:"triggerEvent,omitempty"`
+	TriggerResource      string                 `json:"triggerResource,omitempty"`
 	Memory               string                 `json:"memory,omitempty"`
 	ServiceAccount       string                 `json:"serviceAccount,omitempty"`
 	Source               string                 `json:"source,omitempty"`
 	IngressSettings      string                 `json:"ingressSettings,omitempty"`
 	TimeoutSeconds       int                    `json:"timeout,omitempty"`
 	EnvironmentVariables map[string]interface{} `json:"env,omitempty"`
+	EnvironmentMode      string                 `json:"envMode,omitempty"`
+	RemoveEnvironment    []string               `json:"removeEnv,omitempty"`
 	AllowUnauthenticated bool                   `json:"allowUnauthenticated,omitempty"`
 	EgressSettings       string                 `json:"egressSettings,omitempty"`
 	VPCConnector         string                 `json:"vpcConnector,omitempty"`
+	MinInstances         int                    `json:"minInstances,omitempty"`
+	MaxInstances         int                    `json:"maxInstances,omitempty"`
+
+	// build params
+	BuildEnvironmentVariables map[string]interface{} `json:"buildEnv,omitempty"`
+	BuildWorkerPool           string                 `json:"buildWorkerPool,omitempty"`
+	BuildServiceAccount       string                 `json:"buildServiceAccount,omitempty"`
+
+	// gen2 params
+	Generation          int    `json:"generation,omitempty"`
+	CPU                 string `json:"cpu,omitempty"`
+	Concurrency         int    `json:"concurrency,omitempty"`
+	RuntimeUpdatePolicy string `json:"runtimeUpdatePolicy,omitempty"`
+
+	// eventarc trigger params
+	EventFilters            map[string]string `json:"eventFilters,omitempty"`
+	EventFiltersPathPattern map[string]string `json:"eventFiltersPathPattern,omitempty"`
+	TriggerLocation         string            `json:"triggerLocation,omitempty"`
+	TriggerServiceAccount   string            `json:"triggerServiceAccount,omitempty"`
+	TriggerChannel          string            `json:"triggerChannel,omitempty"`
+
+	// secret manager params
+	Secrets                  map[string]string `json:"secrets,omitempty"`
+	AllowCrossProjectSecrets bool              `json:"allowCrossProjectSecrets,omitempty"`
+
+	// artifact params
+	ArtifactBucket string `json:"artifactBucket,omitempty"`
+
+	// promote params
+	PromoteFrom string `json:"promoteFrom,omitempty"`
+
+	// rollback params
+	SnapshotBucket  string `json:"snapshotBucket,omitempty"`
+	RollbackVersion string `json:"rollbackVersion,omitempty"`
+
+	// scheduler params
+	Schedule *ScheduleParam `json:"schedule,omitempty"`
+
+	// prune params
+	Functions []string `json:"functions,omitempty"`
+
+	// smoke test params
+	SmokeTest *SmokeTestParam `json:"smokeTest,omitempty"`
+
+	// test invocation params
+	TestInvocation *TestInvocationParam `json:"testInvocation,omitempty"`
+	LogScan        *LogScanParam        `json:"logScan,omitempty"`
+	Metrics        *MetricsParam        `json:"metrics,omitempty"`
+
+	// preview params
+	Preview *PreviewParam `json:"preview,omitempty"`
 }
 
 // SetDefaults fills in empty fields with convention-based defaults
-func (p *Params) SetDefaults(gitName, appLabel, buildVersion, releaseName, releaseAction string, estafetteLabels map[string]string) {
+func (p *Params) SetDefaults(gitName, gitBranch, appLabel, buildVersion, releaseName, releaseAction string, estafetteLabels map[string]string) {
 
 	// default app to estafette app label if no override in stage params
 	if p.App == "" && appLabel == "" && gitName != "" {
@@ -37,6 +103,24 @@ func (p *Params) SetDefaults(gitName, appLabel, buildVersion, releaseName, relea
 		p.App = appLabel
 	}
 
+	// default generation to 1st gen
+	if p.Generation <= 0 {
+		p.Generation = 1
+	}
+
+	// default build version to estafette build version
+	if p.BuildVersion == "" && buildVersion != "" {
+		p.BuildVersion = buildVersion
+	}
+
+	// default action to release action, or deploy if there's no release action
+	if p.Action == "" && releaseAction != "" {
+		p.Action = releaseAction
+	}
+	if p.Action == "" {
+		p.Action = "deploy"
+	}
+
 	// default trigger to http-trigger
 	if p.Trigger == "" {
 		p.Trigger = "http"
@@ -47,6 +131,11 @@ func (p *Params) SetDefaults(gitName, appLabel, buildVersion, releaseName, relea
 		p.Memory = "256MB"
 	}
 
+	// default source to the archive packaged in the build stage when releasing
+	if p.Source == "" && p.ArtifactBucket != "" && p.Action != "package" && p.BuildVersion != "" {
+		p.Source = getArtifactPath(p.ArtifactBucket, p.App, p.BuildVersion)
+	}
+
 	// default source to current directory
 	if p.Source == "" {
 		p.Source = "."
@@ -57,6 +146,11 @@ func (p *Params) SetDefaults(gitName, appLabel, buildVersion, releaseName, relea
 		p.TimeoutSeconds = 60
 	}
 
+	// default env mode to replace all environment variables
+	if p.EnvironmentMode == "" {
+		p.EnvironmentMode = "replace"
+	}
+
 	// default ingress-settings to all
 	if p.IngressSettings == "" {
 		p.IngressSettings = "all"
@@ -66,14 +160,168 @@ func (p *Params) SetDefaults(gitName, appLabel, buildVersion, releaseName, relea
 	if p.EgressSettings == "" {
 		p.EgressSettings = "private-ranges-only"
 	}
+
+	if p.Schedule != nil {
+		p.Schedule.SetDefaults()
+	}
+
+	if p.SmokeTest != nil {
+		p.SmokeTest.SetDefaults()
+	}
+
+	if p.TestInvocation != nil {
+		p.TestInvocation.SetDefaults()
+	}
+
+	if p.LogScan != nil {
+		p.LogScan.SetDefaults()
+	}
+
+	if p.Metrics != nil {
+		p.Metrics.SetDefaults()
+	}
+
+	// suffix app with the branch to deploy a preview next to the function itself; cleaning up previews needs the name of the function itself
+	if p.Preview != nil && p.Action != "cleanup-previews" {
+		p.Preview.SetDefaults(gitBranch)
+		p.Preview.App = p.App
+		if p.App != "" && p.Preview.GetSlug() != "" {
+			p.App = getPreviewName(p.App, p.Preview.Branch, getMaxFunctionNameLength(p.Generation))
+		}
+	}
 }
 
 // ValidateRequiredProperties checks whether all needed properties are set
-func (p *Params) ValidateRequiredProperties() (bool, []error, []string) {
+func (p *Params) ValidateRequiredProperties(credential GKECredentials) (bool, []error, []string) {
 
 	errors := []error{}
 	warnings := []string{}
 
+	supportedActions := []string{
+		"deploy",
+		"delete",
+		"describe",
+		"rollback",
+		"package",
+		"promote",
+		"cleanup-previews",
+		"prune",
+		"verify",
+	}
+
+	if !inStringArray(p.Action, supportedActions) {
+		errors = append(errors, fmt.Errorf("Action %v is not supported; set it to %v", p.Action, strings.Join(supportedActions, ", ")))
+	}
+
+	if p.App == "" {
+		errors = append(errors, fmt.Errorf("App is required; set it via app property on this stage, or the app label"))
+	}
+
+	if p.Generation != 1 && p.Generation != 2 {
+		errors = append(errors, fmt.Errorf("Generation %v is not supported; set it to 1 or 2", p.Generation))
+	}
+
+	if len(p.App) > getMaxFunctionNameLength(p.Generation) {
+		errors = append(errors, fmt.Errorf("App %v is longer than %v characters, the maximum length of a function name", p.App, getMaxFunctionNameLength(p.Generation)))
+	}
+
+	if p.Preview != nil {
+		if p.Action == "promote" || p.Action == "package" {
+			errors = append(errors, fmt.Errorf("Preview can't be used for action %v", p.Action))
+		}
+		if len(p.Preview.App) > getMaxFunctionNameLength(p.Generation)-8 {
+			errors = append(errors, fmt.Errorf("App %v is too long to suffix it with the branch for a preview; keep it to %v characters", p.Preview.App, getMaxFunctionNameLength(p.Generation)-8))
+		}
+		_, previewErrors := p.Preview.ValidateRequiredProperties()
+		errors = append(errors, previewErrors...)
+	}
+
+	if len(p.Functions) > 0 && p.Action != "prune" {
+		errors = append(errors, fmt.Errorf("Functions can only be used for action prune"))
+	}
+
+	// the estafette labels are shared by all functions of the pipeline, so defaulting the deployment set would delete the other functions
+	if len(p.Functions) == 0 && p.Action == "prune" {
+		errors = append(errors, fmt.Errorf("Functions is required for action prune; set it to all functions deployed by this pipeline"))
+	}
+
+	if p.SmokeTest != nil {
+		if _, smokeTestErrors := p.SmokeTest.ValidateRequiredProperties(); len(smokeTestErrors) > 0 {
+			errors = append(errors, smokeTestErrors...)
+		}
+	}
+
+	if p.TestInvocation != nil {
+		if _, testInvocationErrors := p.TestInvocation.ValidateRequiredProperties(); len(testInvocationErrors) > 0 {
+			errors = append(errors, testInvocationErrors...)
+		}
+	}
+
+	if p.LogScan != nil {
+		if _, logScanErrors := p.LogScan.ValidateRequiredProperties(); len(logScanErrors) > 0 {
+			errors = append(errors, logScanErrors...)
+		}
+	}
+
+	if p.Metrics != nil {
+		if _, metricsErrors := p.Metrics.ValidateRequiredProperties(); len(metricsErrors) > 0 {
+			errors = append(errors, metricsErrors...)
+		}
+	}
+
+	// cleaning up previews only needs to identify the function they're a copy of, pruning the deployment set
+	if p.Action == "cleanup-previews" || p.Action == "prune" {
+		return len(errors) == 0, errors, warnings
+	}
+
+	if p.ArtifactBucket != "" && !bucketNameRegex.MatchString(p.ArtifactBucket) {
+		errors = append(errors, fmt.Errorf("ArtifactBucket %v is not a valid bucket name; set it to the name of the bucket without gs:// prefix", p.ArtifactBucket))
+	}
+
+	if p.Action == "package" {
+		if p.ArtifactBucket == "" {
+			errors = append(errors, fmt.Errorf("ArtifactBucket is required for action package"))
+		}
+		if p.BuildVersion == "" {
+			errors = append(errors, fmt.Errorf("BuildVersion is required for action package; set it via buildVersion property or run in an estafette build"))
+		}
+		if strings.HasPrefix(p.Source, "gs://") || strings.HasPrefix(p.Source, "https://") {
+			errors = append(errors, fmt.Errorf("Source %v can't be packaged; set it to a local directory", p.Source))
+		}
+		return len(errors) == 0, errors, warnings
+	}
+
+	if p.Action == "promote" {
+		if p.PromoteFrom == "" {
+			errors = append(errors, fmt.Errorf("PromoteFrom is required for action promote; set it to the name of the credential the function is deployed with"))
+		} else if p.PromoteFrom == credential.Name {
+			errors = append(errors, fmt.Errorf("PromoteFrom %v is the credential to promote to; set it to the credential the function is deployed with", p.PromoteFrom))
+		}
+		if p.EnvironmentMode != "replace" || len(p.RemoveEnvironment) > 0 {
+			errors = append(errors, fmt.Errorf("EnvMode and removeEnv can't be used for action promote; env is merged into the environment variables of the promoted function"))
+		}
+		// the function is copied as deployed, except for the overrides
+		return len(errors) == 0, errors, warnings
+	}
+
+	if p.SnapshotBucket != "" && !bucketNameRegex.MatchString(p.SnapshotBucket) {
+		errors = append(errors, fmt.Errorf("SnapshotBucket %v is not a valid bucket name; set it to the name of the bucket without gs:// prefix", p.SnapshotBucket))
+	}
+
+	if p.RollbackVersion != "" && (p.Action != "rollback" || p.SnapshotBucket == "") {
+		errors = append(errors, fmt.Errorf("RollbackVersion can only be used for action rollback with SnapshotBucket set"))
+	}
+
+	if p.Action == "rollback" && p.SnapshotBucket != "" {
+		// rolling back restores the configuration from the snapshot, not from the params
+		return len(errors) == 0, errors, warnings
+	}
+
+	// deleting or describing only needs to identify the function
+	if p.Action == "delete" || p.Action == "describe" {
+		return len(errors) == 0, errors, warnings
+	}
+
 	supportedRuntimes := []string{
 		"nodejs8",
 		"nodejs10",
@@ -82,26 +330,79 @@ func (p *Params) ValidateRequiredProperties() (bool, []error, []string) {
 		"go111",
 		"go113",
 	}
+	if p.Generation == 2 {
+		supportedRuntimes = []string{
+			"nodejs16",
+			"nodejs18",
+			"nodejs20",
+			"nodejs22",
+			"python38",
+			"python39",
+			"python310",
+			"python311",
+			"python312",
+			"go116",
+			"go118",
+			"go119",
+			"go120",
+			"go121",
+			"go122",
+		}
+	}
 
 	if !inStringArray(p.Runtime, supportedRuntimes) {
 		errors = append(errors, fmt.Errorf("Runtime %v is not supported; set it to %v", p.Runtime, strings.Join(supportedRuntimes, ", ")))
 	}
 
-	supportedMemory := []string{
-		"128MB",
-		"256MB",
-		"512MB",
-		"1024MB",
-		"2048MB",
+	if p.EntryPoint != "" && !regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$.]*$`).MatchString(p.EntryPoint) {
+		errors = append(errors, fmt.Errorf("EntryPoint %v is not a valid function name", p.EntryPoint))
+	}
+
+	if p.Generation == 2 {
+		errors = append(errors, p.validateGen2Properties()...)
+	} else {
+		supportedMemory := []string{
+			"128MB",
+			"256MB",
+			"512MB",
+			"1024MB",
+			"2048MB",
+		}
+
+		if !inStringArray(p.Memory, supportedMemory) {
+			errors = append(errors, fmt.Errorf("Memory %v is not supported; set it to %v", p.Memory, strings.Join(supportedMemory, ", ")))
+		}
+
+		if p.CPU != "" {
+			errors = append(errors, fmt.Errorf("CPU is only supported for generation 2; set generation to 2 or remove cpu"))
+		}
+
+		if p.Concurrency != 0 {
+			errors = append(errors, fmt.Errorf("Concurrency is only supported for generation 2; set generation to 2 or remove concurrency"))
+		}
+
+		if p.TimeoutSeconds <= 0 || p.TimeoutSeconds > 540 {
+			errors = append(errors, fmt.Errorf("Timeout %v is not supported; set it between 0 and 540 seconds", p.TimeoutSeconds))
+		}
 	}
 
-	if !inStringArray(p.Memory, supportedMemory) {
-		errors = append(errors, fmt.Errorf("Memory %v is not supported; set it to %v", p.Memory, strings.Join(supportedMemory, ", ")))
+	if p.RuntimeUpdatePolicy != "" {
+		supportedRuntimeUpdatePolicies := []string{
+			"automatic",
+			"on-deploy",
+		}
+
+		if !inStringArray(p.RuntimeUpdatePolicy, supportedRuntimeUpdatePolicies) {
+			errors = append(errors, fmt.Errorf("RuntimeUpdatePolicy %v is not supported; set it to %v", p.RuntimeUpdatePolicy, strings.Join(supportedRuntimeUpdatePolicies, ", ")))
+		}
 	}
 
 	supportedTrigger := []string{
 		"http",
 		"bucket",
+		"topic",
+		"event",
+		"eventarc",
 	}
 
 	if !inStringArray(p.Trigger, supportedTrigger) {
@@ -112,13 +413,70 @@ func (p *Params) ValidateRequiredProperties() (bool, []error, []string) {
 		errors = append(errors, fmt.Errorf("TriggerValue is required when Trigger is bucket; set TriggerValue as well"))
 	}
 
-	if p.TimeoutSeconds <= 0 || p.TimeoutSeconds > 540 {
-		errors = append(errors, fmt.Errorf("Timeout %v is not supported; set it between 0 and 540 seconds", p.Memory))
+	if p.Trigger == "topic" {
+		if p.TriggerValue == "" {
+			errors = append(errors, fmt.Errorf("TriggerValue is required when Trigger is topic; set TriggerValue to the name of the topic"))
+		} else if !isValidTopicName(p.TriggerValue) {
+			errors = append(errors, fmt.Errorf("TriggerValue %v is not a valid topic name; it has to start with a letter, be 3 to 255 characters long, not start with goog and only contain letters, numbers, dashes, periods, underscores, tildes, percent or plus signs", p.TriggerValue))
+		}
+	}
+
+	if p.Trigger == "event" {
+		if eventType, ok := supportedEventTypes[p.TriggerEvent]; !ok {
+			errors = append(errors, fmt.Errorf("TriggerEvent %v is not supported when Trigger is event; set it to %v", p.TriggerEvent, strings.Join(getSupportedEventTypeNames(), ", ")))
+		} else if p.TriggerResource == "" {
+			errors = append(errors, fmt.Errorf("TriggerResource is required when Trigger is event; set it to %v", eventType.ResourceFormat))
+		} else if !eventType.IsValidResource(p.TriggerResource) {
+			errors = append(errors, fmt.Errorf("TriggerResource %v is not valid for TriggerEvent %v; set it to %v", p.TriggerResource, p.TriggerEvent, eventType.ResourceFormat))
+		}
+	}
+
+	errors = append(errors, p.validateEventarcProperties()...)
+
+	errors = append(errors, p.validateSecrets(credential.AdditionalProperties.Project)...)
+
+	if p.BuildWorkerPool != "" {
+		matches := regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)/workerPools/[a-z][a-z0-9-]{0,62}$`).FindStringSubmatch(p.BuildWorkerPool)
+		if matches == nil {
+			errors = append(errors, fmt.Errorf("BuildWorkerPool %v is not valid; set it to projects/{project}/locations/{region}/workerPools/{workerPool}", p.BuildWorkerPool))
+		} else if matches[1] != credential.AdditionalProperties.Project || matches[2] != credential.AdditionalProperties.Region {
+			errors = append(errors, fmt.Errorf("BuildWorkerPool %v is not in project %v and region %v of credential %v", p.BuildWorkerPool, credential.AdditionalProperties.Project, credential.AdditionalProperties.Region, credential.Name))
+		}
+	}
+
+	if p.BuildServiceAccount != "" && !regexp.MustCompile(`^projects/[^/]+/serviceAccounts/[^@/\s]+@[^@/\s]+$`).MatchString(p.BuildServiceAccount) {
+		errors = append(errors, fmt.Errorf("BuildServiceAccount %v is not valid; set it to projects/{project}/serviceAccounts/{email}", p.BuildServiceAccount))
+	}
+
+	envErrors, envWarnings := p.validateEnvironmentMode()
+	errors = append(errors, envErrors...)
+	warnings = append(warnings, envWarnings...)
+
+	if p.SmokeTest != nil && p.Trigger != "http" {
+		errors = append(errors, fmt.Errorf("SmokeTest is only supported when Trigger is http"))
+	}
+
+	if p.TestInvocation != nil && p.Trigger == "http" {
+		errors = append(errors, fmt.Errorf("TestInvocation is only supported for event triggers; use smokeTest for http functions"))
+	}
+
+	if p.Schedule != nil {
+		if p.Trigger != "http" {
+			errors = append(errors, fmt.Errorf("Schedule is only supported when Trigger is http"))
+		}
+		if _, scheduleErrors := p.Schedule.ValidateRequiredProperties(); len(scheduleErrors) > 0 {
+			errors = append(errors, scheduleErrors...)
+		}
+	}
+
+	if p.CreateTopic && p.Trigger != "topic" {
+		warnings = append(warnings, fmt.Sprintf("CreateTopic is ignored when Trigger is %v", p.Trigger))
 	}
 
 	supportedIngressSettings := []string{
 		"all",
 		"internal-only",
+		"internal-and-gclb",
 	}
 
 	if !inStringArray(p.IngressSettings, supportedIngressSettings) {
@@ -134,9 +492,67 @@ func (p *Params) ValidateRequiredProperties() (bool, []error, []string) {
 		errors = append(errors, fmt.Errorf("EgressSettings %v is not supported; set it to %v", p.EgressSettings, strings.Join(supportedEgressSettings, ", ")))
 	}
 
+	if p.MinInstances < 0 || p.MaxInstances < 0 {
+		errors = append(errors, fmt.Errorf("MinInstances and MaxInstances can't be negative"))
+	}
+
+	if p.MaxInstances > 0 && p.MinInstances > p.MaxInstances {
+		errors = append(errors, fmt.Errorf("MinInstances %v is larger than MaxInstances %v", p.MinInstances, p.MaxInstances))
+	}
+
 	return len(errors) == 0, errors, warnings
 }
 
+func (p *Params) validateGen2Properties() (errors []error) {
+
+	memory, err := getGen2Memory(p.Memory)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	cpu := p.CPU
+	if memory != nil {
+		if cpu == "" {
+			cpu = memory.DefaultCPU
+		}
+		supportedCPU := getSupportedGen2CPU(*memory)
+		if !inStringArray(cpu, supportedCPU) {
+			errors = append(errors, fmt.Errorf("CPU %v is not supported for memory %v; set it to %v", cpu, p.Memory, strings.Join(supportedCPU, ", ")))
+		}
+	}
+
+	if p.Concurrency < 0 || p.Concurrency > 1000 {
+		errors = append(errors, fmt.Errorf("Concurrency %v is not supported; set it between 1 and 1000", p.Concurrency))
+	}
+
+	if p.Concurrency > 1 {
+		if cpuValue, err := strconv.ParseFloat(cpu, 64); err == nil && cpuValue < 1 {
+			errors = append(errors, fmt.Errorf("Concurrency larger than 1 requires at least 1 cpu; set cpu to 1 or more"))
+		}
+	}
+
+	// http functions can run up to 60 minutes, event-driven functions are still limited to 9 minutes
+	maxTimeoutSeconds := 540
+	if p.Trigger == "http" {
+		maxTimeoutSeconds = 3600
+	}
+	if p.TimeoutSeconds <= 0 || p.TimeoutSeconds > maxTimeoutSeconds {
+		errors = append(errors, fmt.Errorf("Timeout %v is not supported for generation 2 with trigger %v; set it between 0 and %v seconds", p.TimeoutSeconds, p.Trigger, maxTimeoutSeconds))
+	}
+
+	if p.Trigger == "event" {
+		errors = append(errors, fmt.Errorf("Trigger event is not supported for generation 2"))
+	}
+
+	return errors
+}
+
+// a valid topic name starts with a letter, is between 3 and 255 characters long and doesn't start with goog, see https://cloud.google.com/pubsub/docs/admin#resource_names
+func isValidTopicName(name string) bool {
+	reg := regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-_.~+%]{2,254}$`)
+	return reg.MatchString(name) && !strings.HasPrefix(strings.ToLower(name), "goog")
+}
+
 func inStringArray(value string, array []string) bool {
 	for _, v := range array {
 		if v == value {
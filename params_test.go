@@ -10,6 +10,8 @@ var (
 	trueValue   = true
 	falseValue  = false
 	validParams = Params{
+		Action:          "deploy",
+		App:             "myfunction",
 		Runtime:         "go111",
 		Memory:          "256MB",
 		Trigger:         "http",
@@ -17,9 +19,15 @@ var (
 		IngressSettings: "all",
 		EgressSettings:  "private-ranges-only",
 		TimeoutSeconds:  60,
+		Generation:      1,
+		EnvironmentMode: "replace",
 	}
 	validCredential = GKECredentials{
 		Name: "gke-production",
+		AdditionalProperties: GKECredentialAdditionalProperties{
+			Project: "my-project",
+			Region:  "europe-west1",
+		},
 	}
 )
 
@@ -33,7 +41,7 @@ func TestSetDefaults(t *testing.T) {
 		appLabel := ""
 
 		// act
-		params.SetDefaults(gitName, appLabel, "", "", "", map[string]string{})
+		params.SetDefaults(gitName, "", appLabel, "", "", "", map[string]string{})
 
 		assert.Equal(t, "mygitrepo", params.App)
 	})
@@ -46,7 +54,7 @@ func TestSetDefaults(t *testing.T) {
 		appLabel := "myapp"
 
 		// act
-		params.SetDefaults("", appLabel, "", "", "", map[string]string{})
+		params.SetDefaults("", "", appLabel, "", "", "", map[string]string{})
 
 		assert.Equal(t, "myapp", params.App)
 	})
@@ -59,11 +67,129 @@ func TestSetDefaults(t *testing.T) {
 		appLabel := "myapp"
 
 		// act
-		params.SetDefaults("", appLabel, "", "", "", map[string]string{})
+		params.SetDefaults("", "", appLabel, "", "", "", map[string]string{})
 
 		assert.Equal(t, "yourapp", params.App)
 	})
 
+	t.Run("DefaultsBuildVersionToEstafetteBuildVersionIfEmpty", func(t *testing.T) {
+
+		params := Params{
+			BuildVersion: "",
+		}
+		buildVersion := "1.0.5"
+
+		// act
+		params.SetDefaults("", "", "", buildVersion, "", "", map[string]string{})
+
+		assert.Equal(t, "1.0.5", params.BuildVersion)
+	})
+
+	t.Run("DefaultsSourceToArtifactIfArtifactBucketIsSet", func(t *testing.T) {
+
+		params := Params{
+			App:            "myfunction",
+			ArtifactBucket: "my-artifacts",
+		}
+
+		// act
+		params.SetDefaults("", "", "", "1.0.5", "production", "", map[string]string{})
+
+		assert.Equal(t, "gs://my-artifacts/myfunction/1.0.5.zip", params.Source)
+	})
+
+	t.Run("DefaultsSourceToCurrentDirectoryForActionPackage", func(t *testing.T) {
+
+		params := Params{
+			App:            "myfunction",
+			Action:         "package",
+			ArtifactBucket: "my-artifacts",
+		}
+
+		// act
+		params.SetDefaults("", "", "", "1.0.5", "", "", map[string]string{})
+
+		assert.Equal(t, ".", params.Source)
+	})
+
+	t.Run("SuffixesAppWithBranchIfPreviewIsSet", func(t *testing.T) {
+
+		params := Params{
+			App:     "myfunction",
+			Preview: &PreviewParam{},
+		}
+
+		// act
+		params.SetDefaults("", "feature/add-login", "", "", "", "", map[string]string{})
+
+		assert.Equal(t, "myfunction-feature-add-login", params.App)
+		assert.Equal(t, "myfunction", params.Preview.App)
+	})
+
+	t.Run("KeepsAppIfPreviewIsSetForActionCleanupPreviews", func(t *testing.T) {
+
+		params := Params{
+			App:     "myfunction",
+			Action:  "cleanup-previews",
+			Preview: &PreviewParam{},
+		}
+
+		// act
+		params.SetDefaults("", "feature/add-login", "", "", "", "", map[string]string{})
+
+		assert.Equal(t, "myfunction", params.App)
+	})
+
+	t.Run("DoesNotDefaultFunctionsForActionPrune", func(t *testing.T) {
+
+		params := Params{
+			App:    "myfunction",
+			Action: "prune",
+		}
+
+		// act
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
+
+		assert.Equal(t, 0, len(params.Functions))
+	})
+
+	t.Run("DefaultsActionToReleaseActionIfEmpty", func(t *testing.T) {
+
+		params := Params{
+			Action: "",
+		}
+		releaseAction := "delete"
+
+		// act
+		params.SetDefaults("", "", "", "", "", releaseAction, map[string]string{})
+
+		assert.Equal(t, "delete", params.Action)
+	})
+
+	t.Run("DefaultsActionToDeployIfEmptyAndReleaseActionIsEmpty", func(t *testing.T) {
+
+		params := Params{
+			Action: "",
+		}
+
+		// act
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
+
+		assert.Equal(t, "deploy", params.Action)
+	})
+
+	t.Run("KeepsActionIfNotEmpty", func(t *testing.T) {
+
+		params := Params{
+			Action: "describe",
+		}
+
+		// act
+		params.SetDefaults("", "", "", "", "", "delete", map[string]string{})
+
+		assert.Equal(t, "describe", params.Action)
+	})
+
 	t.Run("DefaultsMemoryTo256MB", func(t *testing.T) {
 
 		params := Params{
@@ -71,7 +197,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, "256MB", params.Memory)
 	})
@@ -83,7 +209,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, "128MB", params.Memory)
 	})
@@ -95,7 +221,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, "http", params.Trigger)
 	})
@@ -108,7 +234,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, trigger, params.Trigger)
 	})
@@ -120,7 +246,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, ".", params.Source)
 	})
@@ -132,7 +258,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, "otherpath/", params.Source)
 	})
@@ -144,7 +270,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, 60, params.TimeoutSeconds)
 	})
@@ -156,11 +282,59 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, 30, params.TimeoutSeconds)
 	})
 
+	t.Run("DefaultsGenerationTo1", func(t *testing.T) {
+
+		params := Params{
+			Generation: 0,
+		}
+
+		// act
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
+
+		assert.Equal(t, 1, params.Generation)
+	})
+
+	t.Run("KeepsGenerationIfLargerThanZero", func(t *testing.T) {
+
+		params := Params{
+			Generation: 2,
+		}
+
+		// act
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
+
+		assert.Equal(t, 2, params.Generation)
+	})
+
+	t.Run("DefaultsEnvironmentModeToReplace", func(t *testing.T) {
+
+		params := Params{
+			EnvironmentMode: "",
+		}
+
+		// act
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
+
+		assert.Equal(t, "replace", params.EnvironmentMode)
+	})
+
+	t.Run("KeepsEnvironmentModeIfNotEmpty", func(t *testing.T) {
+
+		params := Params{
+			EnvironmentMode: "merge",
+		}
+
+		// act
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
+
+		assert.Equal(t, "merge", params.EnvironmentMode)
+	})
+
 	t.Run("DefaultsIngressSettingsToAll", func(t *testing.T) {
 
 		params := Params{
@@ -168,7 +342,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, "all", params.IngressSettings)
 	})
@@ -180,7 +354,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, "internal-only", params.IngressSettings)
 	})
@@ -192,7 +366,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, "private-ranges-only", params.EgressSettings)
 	})
@@ -204,7 +378,7 @@ func TestSetDefaults(t *testing.T) {
 		}
 
 		// act
-		params.SetDefaults("", "", "", "", "", map[string]string{})
+		params.SetDefaults("", "", "", "", "", "", map[string]string{})
 
 		assert.Equal(t, "all", params.EgressSettings)
 	})
@@ -212,134 +386,1031 @@ func TestSetDefaults(t *testing.T) {
 
 func TestValidateRequiredProperties(t *testing.T) {
 
-	t.Run("ReturnsFalseIfRuntimeIsNotSupported", func(t *testing.T) {
+	t.Run("ReturnsFalseIfActionIsNotSupported", func(t *testing.T) {
 
 		params := validParams
-		params.Runtime = "nodejs6"
+		params.Action = "undeploy"
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.False(t, valid)
 		assert.True(t, len(errors) > 0)
 	})
 
-	t.Run("ReturnsTrueIfRuntimeIsSupported", func(t *testing.T) {
+	t.Run("ReturnsFalseIfAppIsEmpty", func(t *testing.T) {
 
 		params := validParams
-		params.Runtime = "go111"
+		params.App = ""
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfRuntimeIsEmptyForActionDelete", func(t *testing.T) {
+
+		params := Params{
+			Action:     "delete",
+			App:        "myfunction",
+			Generation: 1,
+		}
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.True(t, valid)
 		assert.True(t, len(errors) == 0)
 	})
 
-	t.Run("ReturnsFalseIfTriggerValueIsEmptyForTriggerBucket", func(t *testing.T) {
+	t.Run("ReturnsTrueIfRuntimeIsEmptyForActionDescribe", func(t *testing.T) {
+
+		params := Params{
+			Action:     "describe",
+			App:        "myfunction",
+			Generation: 2,
+		}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfRuntimeIsEmptyForActionRollback", func(t *testing.T) {
 
 		params := validParams
-		params.Trigger = "bucket"
+		params.Action = "rollback"
+		params.Runtime = ""
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.False(t, valid)
 		assert.True(t, len(errors) > 0)
 	})
 
-	t.Run("ReturnsTrueIfTriggerValueIsNotEmptyForTriggerBucket", func(t *testing.T) {
+	t.Run("ReturnsTrueIfRuntimeIsEmptyForActionRollbackWithSnapshotBucket", func(t *testing.T) {
 
 		params := validParams
-		params.Trigger = "bucket"
-		params.TriggerValue = "bucketName"
+		params.Action = "rollback"
+		params.SnapshotBucket = "my-snapshots"
+		params.Runtime = ""
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.True(t, valid)
 		assert.True(t, len(errors) == 0)
 	})
 
-	t.Run("ReturnsFalseIfTriggerIsNotSupported", func(t *testing.T) {
+	t.Run("ReturnsTrueIfRuntimeIsEmptyForActionPackage", func(t *testing.T) {
 
 		params := validParams
-		params.Trigger = "trigger"
+		params.Action = "package"
+		params.ArtifactBucket = "my-artifacts"
+		params.BuildVersion = "1.0.5"
+		params.Runtime = ""
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfArtifactBucketIsEmptyForActionPackage", func(t *testing.T) {
+
+		params := validParams
+		params.Action = "package"
+		params.BuildVersion = "1.0.5"
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.False(t, valid)
 		assert.True(t, len(errors) > 0)
 	})
 
-	t.Run("ReturnsTrueIfTriggerIsSupported", func(t *testing.T) {
+	t.Run("ReturnsFalseIfSourceIsAnArchiveForActionPackage", func(t *testing.T) {
 
 		params := validParams
-		params.Trigger = "http"
+		params.Action = "package"
+		params.ArtifactBucket = "my-artifacts"
+		params.BuildVersion = "1.0.5"
+		params.Source = "gs://my-artifacts/myfunction/1.0.4.zip"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfRuntimeIsEmptyForActionPromote", func(t *testing.T) {
+
+		params := validParams
+		params.Action = "promote"
+		params.PromoteFrom = "gke-staging"
+		params.Runtime = ""
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.True(t, valid)
 		assert.True(t, len(errors) == 0)
 	})
 
-	t.Run("ReturnsFalseIfMemoryIsNotSupported", func(t *testing.T) {
+	t.Run("ReturnsFalseIfPromoteFromIsEmptyForActionPromote", func(t *testing.T) {
 
 		params := validParams
-		params.Memory = "64MB"
+		params.Action = "promote"
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.False(t, valid)
 		assert.True(t, len(errors) > 0)
 	})
 
-	t.Run("ReturnsTrueIfMemoryIsSupported", func(t *testing.T) {
+	t.Run("ReturnsFalseIfPromoteFromIsTheCredentialForActionPromote", func(t *testing.T) {
 
 		params := validParams
-		params.Memory = "512MB"
+		params.Action = "promote"
+		params.PromoteFrom = "gke-production"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfFunctionsIsNotSetForActionPrune", func(t *testing.T) {
+
+		params := validParams
+		params.Action = "prune"
+		params.Functions = nil
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfFunctionsIsSetForActionDeploy", func(t *testing.T) {
+
+		params := validParams
+		params.Functions = []string{"myfunction", "myotherfunction"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfMinInstancesIsLargerThanMaxInstances", func(t *testing.T) {
+
+		params := validParams
+		params.MinInstances = 5
+		params.MaxInstances = 2
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfSnapshotBucketIsNotAValidBucketName", func(t *testing.T) {
+
+		params := validParams
+		params.SnapshotBucket = "gs://my-snapshots"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfRollbackVersionIsSetForActionDeploy", func(t *testing.T) {
+
+		params := validParams
+		params.SnapshotBucket = "my-snapshots"
+		params.RollbackVersion = "1.0.3"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfRollbackVersionIsSetWithoutSnapshotBucket", func(t *testing.T) {
+
+		params := validParams
+		params.Action = "rollback"
+		params.RollbackVersion = "1.0.3"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfRuntimeIsNotSupported", func(t *testing.T) {
+
+		params := validParams
+		params.Runtime = "nodejs6"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfRuntimeIsSupported", func(t *testing.T) {
+
+		params := validParams
+		params.Runtime = "go111"
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.True(t, valid)
 		assert.True(t, len(errors) == 0)
 	})
 
-	t.Run("ReturnsFalseIfTimeoutSecondsIsLargerThan540Seconds", func(t *testing.T) {
+	t.Run("ReturnsFalseIfTriggerValueIsEmptyForTriggerBucket", func(t *testing.T) {
 
 		params := validParams
-		params.TimeoutSeconds = 541
+		params.Trigger = "bucket"
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.False(t, valid)
 		assert.True(t, len(errors) > 0)
 	})
 
-	t.Run("ReturnsTrueIfTimeoutSecondsIsLessThan540Seconds", func(t *testing.T) {
+	t.Run("ReturnsTrueIfTriggerValueIsNotEmptyForTriggerBucket", func(t *testing.T) {
 
 		params := validParams
-		params.TimeoutSeconds = 540
+		params.Trigger = "bucket"
+		params.TriggerValue = "bucketName"
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.True(t, valid)
 		assert.True(t, len(errors) == 0)
 	})
 
+	t.Run("ReturnsFalseIfTriggerValueIsEmptyForTriggerTopic", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "topic"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfTriggerValueIsInvalidTopicNameForTriggerTopic", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "topic"
+		params.TriggerValue = "google-topic"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfTriggerValueIsValidTopicNameForTriggerTopic", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "topic"
+		params.TriggerValue = "my-topic_1.events"
+		params.CreateTopic = true
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfTriggerEventIsNotSupportedForTriggerEvent", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "event"
+		params.TriggerEvent = "providers/cloud.unknown/eventTypes/thing.happened"
+		params.TriggerResource = "projects/my-project"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfTriggerResourceIsEmptyForTriggerEvent", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "event"
+		params.TriggerEvent = "google.storage.object.delete"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfTriggerResourceDoesNotMatchFormatOfTriggerEvent", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "event"
+		params.TriggerEvent = "providers/cloud.firestore/eventTypes/document.write"
+		params.TriggerResource = "projects/my-project/databases/(default)"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfTriggerResourceMatchesFormatOfTriggerEvent", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "event"
+		params.TriggerEvent = "providers/cloud.firestore/eventTypes/document.write"
+		params.TriggerResource = "projects/my-project/databases/(default)/documents/users/{userId}"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfTriggerIsEventarcForGeneration1", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.storage.object.v1.finalized", "bucket": "bucketName"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfEventFiltersHasNoTypeForTriggerEventarc", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"bucket": "bucketName"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfEventFiltersLacksMethodNameForAuditLogEvents", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.audit.log.v1.written", "serviceName": "storage.googleapis.com"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfAttributeIsInBothEventFiltersAndEventFiltersPathPattern", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.audit.log.v1.written", "serviceName": "storage.googleapis.com", "methodName": "storage.objects.create", "resourceName": "projects/_/buckets/b"}
+		params.EventFiltersPathPattern = map[string]string{"resourceName": "/projects/_/buckets/b/objects/*.txt"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfEventFiltersPathPatternIsNotSupportedForEventType", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.storage.object.v1.finalized", "bucket": "bucketName"}
+		params.EventFiltersPathPattern = map[string]string{"name": "/images/*"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfTriggerLocationIsSetForTriggerHttp", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.TriggerLocation = "europe-west1"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfEventarcTriggerForAuditLogEventsIsValid", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.audit.log.v1.written", "serviceName": "storage.googleapis.com", "methodName": "storage.objects.create"}
+		params.EventFiltersPathPattern = map[string]string{"resourceName": "/projects/_/buckets/b/objects/*.txt"}
+		params.TriggerLocation = "global"
+		params.TriggerServiceAccount = "eventarc-trigger@my-project.iam.gserviceaccount.com"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsTrueIfTriggerLocationIsMultiRegion", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.firestore.document.v1.written", "database": "(default)"}
+		params.EventFiltersPathPattern = map[string]string{"document": "users/{userId}"}
+		params.TriggerLocation = "nam5"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfTriggerLocationIsNotALocation", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "google.cloud.firestore.document.v1.written", "database": "(default)"}
+		params.TriggerLocation = "Europe West1"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfEventarcTriggerForCustomChannelIsValid", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "eventarc"
+		params.EventFilters = map[string]string{"type": "com.example.order.created"}
+		params.TriggerChannel = "projects/my-project/locations/europe-west1/channels/orders"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfScheduleIsSetForTriggerBucket", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "bucket"
+		params.TriggerValue = "bucketName"
+		params.Schedule = &validScheduleParam
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfTriggerIsNotSupported", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "trigger"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfTriggerIsSupported", func(t *testing.T) {
+
+		params := validParams
+		params.Trigger = "http"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfMemoryIsNotSupported", func(t *testing.T) {
+
+		params := validParams
+		params.Memory = "64MB"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfMemoryIsSupported", func(t *testing.T) {
+
+		params := validParams
+		params.Memory = "512MB"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfTimeoutSecondsIsLargerThan540Seconds", func(t *testing.T) {
+
+		params := validParams
+		params.TimeoutSeconds = 541
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfTimeoutSecondsIsLessThan540Seconds", func(t *testing.T) {
+
+		params := validParams
+		params.TimeoutSeconds = 540
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfGenerationIsNotSupported", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 3
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfMemoryIsNotSupportedForGeneration1", func(t *testing.T) {
+
+		params := validParams
+		params.Memory = "4Gi"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfConcurrencyIsSetForGeneration1", func(t *testing.T) {
+
+		params := validParams
+		params.Concurrency = 10
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfMemoryIsSupportedForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Memory = "32Gi"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsTrueIfRuntimeIsSupportedForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "python311"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfRuntimeIsOnlySupportedForGeneration1", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go111"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfIngressSettingsIsInternalAndGclb", func(t *testing.T) {
+
+		params := validParams
+		params.IngressSettings = "internal-and-gclb"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfMemoryIsNotSupportedForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Memory = "64Gi"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfCPUDoesNotMatchMemoryForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Memory = "32Gi"
+		params.CPU = "2"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfConcurrencyIsLargerThan1WithFractionalCPUForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Memory = "512Mi"
+		params.Concurrency = 80
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfConcurrencyIsLargerThan1WithWholeCPUForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Memory = "512Mi"
+		params.CPU = "1"
+		params.Concurrency = 80
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsTrueIfTimeoutSecondsIs3600SecondsForHttpTriggerForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.TimeoutSeconds = 3600
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfTimeoutSecondsIsLargerThan540SecondsForBucketTriggerForGeneration2", func(t *testing.T) {
+
+		params := validParams
+		params.Generation = 2
+		params.Runtime = "go121"
+		params.Trigger = "bucket"
+		params.TriggerValue = "bucketName"
+		params.TimeoutSeconds = 541
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfRuntimeUpdatePolicyIsNotSupported", func(t *testing.T) {
+
+		params := validParams
+		params.RuntimeUpdatePolicy = "sometimes"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfSecretReferenceIsNotValid", func(t *testing.T) {
+
+		params := validParams
+		params.Secrets = map[string]string{"API_KEY": "projects/my-project/secrets/api-key/versions/"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfSecretIsAlsoSetAsEnvironmentVariable", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentVariables = map[string]interface{}{"API_KEY": "plain"}
+		params.Secrets = map[string]string{"API_KEY": "api-key:1"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfSecretPointsAtOtherProject", func(t *testing.T) {
+
+		params := validParams
+		params.Secrets = map[string]string{"API_KEY": "projects/other-project/secrets/api-key/versions/latest"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfSecretPointsAtOtherProjectAndCrossProjectSecretsAreAllowed", func(t *testing.T) {
+
+		params := validParams
+		params.Secrets = map[string]string{"API_KEY": "projects/other-project/secrets/api-key/versions/latest"}
+		params.AllowCrossProjectSecrets = true
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsTrueIfSecretsAreValid", func(t *testing.T) {
+
+		params := validParams
+		params.Secrets = map[string]string{
+			"API_KEY":               "projects/my-project/secrets/api-key/versions/3",
+			"DB_PASSWORD":           "db-password",
+			"/etc/secrets/cert.pem": "tls-cert:latest",
+		}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfEnvironmentModeIsNotSupported", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentMode = "append"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfRemoveEnvironmentIsSetForEnvironmentModeReplace", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentMode = "replace"
+		params.RemoveEnvironment = []string{"OLD"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfEnvironmentVariablesAreSetForEnvironmentModeClear", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentMode = "clear"
+		params.EnvironmentVariables = map[string]interface{}{"NEW": "value"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfEnvironmentVariableIsSetAndRemovedForEnvironmentModeMerge", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentMode = "merge"
+		params.EnvironmentVariables = map[string]interface{}{"NEW": "value"}
+		params.RemoveEnvironment = []string{"NEW"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfEnvironmentVariablesAreSetAndRemovedForEnvironmentModeMerge", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentMode = "merge"
+		params.EnvironmentVariables = map[string]interface{}{"NEW": "value"}
+		params.RemoveEnvironment = []string{"OLD"}
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfBuildWorkerPoolIsNotValid", func(t *testing.T) {
+
+		params := validParams
+		params.BuildWorkerPool = "my-pool"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfBuildWorkerPoolIsInOtherRegion", func(t *testing.T) {
+
+		params := validParams
+		params.BuildWorkerPool = "projects/my-project/locations/us-central1/workerPools/my-pool"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfBuildWorkerPoolIsInProjectAndRegionOfCredential", func(t *testing.T) {
+
+		params := validParams
+		params.BuildWorkerPool = "projects/my-project/locations/europe-west1/workerPools/my-pool"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfBuildServiceAccountIsNotAResourceName", func(t *testing.T) {
+
+		params := validParams
+		params.BuildServiceAccount = "builder@my-project.iam.gserviceaccount.com"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfBuildServiceAccountIsAResourceName", func(t *testing.T) {
+
+		params := validParams
+		params.BuildServiceAccount = "projects/my-project/serviceAccounts/builder@my-project.iam.gserviceaccount.com"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfEntryPointIsNotAValidFunctionName", func(t *testing.T) {
+
+		params := validParams
+		params.EntryPoint = "my-function"
+
+		// act
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
 	t.Run("ReturnsFalseIfIngressSettingsIsNotSupported", func(t *testing.T) {
 
 		params := validParams
 		params.IngressSettings = "doodah"
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.False(t, valid)
 		assert.True(t, len(errors) > 0)
@@ -351,7 +1422,7 @@ func TestValidateRequiredProperties(t *testing.T) {
 		params.IngressSettings = "internal-only"
 
 		// act
-		valid, errors, _ := params.ValidateRequiredProperties()
+		valid, errors, _ := params.ValidateRequiredProperties(validCredential)
 
 		assert.True(t, valid)
 		assert.True(t, len(errors) == 0)
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// driftExitCode is returned by a dry run if the deployment would change the function, so a stage can gate on it
+const driftExitCode = 2
+
+const (
+	// gen1InvokerRole allows invoking a gen1 function, set on the function itself
+	gen1InvokerRole = "roles/cloudfunctions.invoker"
+	// gen2InvokerRole allows invoking a gen2 function, set on the cloud run service underneath it
+	gen2InvokerRole = "roles/run.invoker"
+)
+
+// PlanChange is a difference between the deployed function and the one the params would deploy
+type PlanChange struct {
+	Field   string
+	Current string
+	Desired string
+	Risk    string
+}
+
+// String renders the change as a line of the plan
+func (c PlanChange) String() string {
+	line := fmt.Sprintf("%v: %v => %v", c.Field, c.Current, c.Desired)
+	if c.Risk != "" {
+		line += fmt.Sprintf(" (%v)", c.Risk)
+	}
+	return line
+}
+
+// IAMPolicy represents the output of gcloud functions get-iam-policy --format json
+type IAMPolicy struct {
+	Bindings []IAMBinding `json:"bindings"`
+}
+
+// IAMBinding grants a role to members
+type IAMBinding struct {
+	Role    string   `json:"role"`
+	Members []string `json:"members"`
+}
+
+// AllowsUnauthenticated returns true if anyone can invoke the function
+func (p *IAMPolicy) AllowsUnauthenticated() bool {
+	for _, binding := range p.Bindings {
+		if binding.Role == gen1InvokerRole || binding.Role == gen2InvokerRole {
+			if inStringArray("allUsers", binding.Members) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetInvokers returns the members of the invoker roles of either generation, sorted
+func (p *IAMPolicy) GetInvokers() []string {
+	invokers := []string{}
+	for _, binding := range p.Bindings {
+		if binding.Role != gen1InvokerRole && binding.Role != gen2InvokerRole {
+			continue
+		}
+		for _, member := range binding.Members {
+			if !inStringArray(member, invokers) {
+				invokers = append(invokers, member)
+			}
+		}
+	}
+	sort.Strings(invokers)
+	return invokers
+}
+
+// getIAMPolicy retrieves the iam policy of the function; extra arguments like --project and --account read it in another project
+func getIAMPolicy(ctx context.Context, name, region string, generation int, extraArguments ...string) (*IAMPolicy, error) {
+
+	arguments := []string{"functions", "get-iam-policy", name, "--region", region, "--format", "json"}
+	if generation == 2 {
+		arguments = append(arguments, "--gen2")
+	}
+	arguments = append(arguments, extraArguments...)
+
+	output, err := getCommandWithArgsOutput(ctx, "gcloud", arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy IAMPolicy
+	err = json.Unmarshal([]byte(output), &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// getInvokerArguments returns the arguments to add or remove an invoker of the function; gen2 functions are invoked through their cloud run service
+func getInvokerArguments(action string, params Params, region, member string) []string {
+	if params.Generation == 2 {
+		return []string{"functions", action + "-invoker-policy-binding", params.App, "--region", region, "--member", member}
+	}
+	return []string{"functions", action + "-iam-policy-binding", params.App, "--region", region, "--member", member, "--role", gen1InvokerRole}
+}
+
+// getTriggerDescription describes the trigger of the params in one line
+func getTriggerDescription(p Params) string {
+	switch p.Trigger {
+	case "bucket", "topic":
+		return fmt.Sprintf("%v %v", p.Trigger, p.TriggerValue)
+	case "event":
+		return fmt.Sprintf("event %v on %v", p.TriggerEvent, p.TriggerResource)
+	case "eventarc":
+		filters := []string{}
+		for _, k := range sortedKeys(p.EventFilters) {
+			filters = append(filters, fmt.Sprintf("%v=%v", k, p.EventFilters[k]))
+		}
+		for _, k := range sortedKeys(p.EventFiltersPathPattern) {
+			filters = append(filters, fmt.Sprintf("%v~%v", k, p.EventFiltersPathPattern[k]))
+		}
+		return fmt.Sprintf("eventarc %v", strings.Join(filters, ","))
+	}
+	return p.Trigger
+}
+
+// getDesiredEnvironmentVariables returns the environment variables the function has after deploying with the params, applying the env mode to the current ones
+func getDesiredEnvironmentVariables(current map[string]interface{}, desired Params) map[string]string {
+
+	environmentVariables := map[string]string{}
+	if desired.EnvironmentMode == "clear" {
+		return environmentVariables
+	}
+
+	// replacing without environment variables passes no env flag, so the current ones are kept as well
+	if desired.EnvironmentMode == "merge" || len(desired.EnvironmentVariables) == 0 {
+		for k, v := range current {
+			environmentVariables[k], _ = getEnvironmentVariableValue(v)
+		}
+	}
+	if desired.EnvironmentMode == "merge" {
+		for _, k := range desired.RemoveEnvironment {
+			delete(environmentVariables, k)
+		}
+	}
+
+	for k, v := range desired.EnvironmentVariables {
+		environmentVariables[k], _ = getEnvironmentVariableValue(v)
+	}
+
+	return environmentVariables
+}
+
+// getEnvironmentVariableChanges returns the keys that are added, removed or get another value; values are left out since they may be sensitive
+func getEnvironmentVariableChanges(current map[string]interface{}, desired map[string]string) (added, removed, changed []string) {
+
+	for k, v := range desired {
+		currentValue, ok := current[k]
+		if !ok {
+			added = append(added, k)
+			continue
+		}
+		if value, _ := getEnvironmentVariableValue(currentValue); value != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range current {
+		if _, ok := desired[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+
+	return
+}
+
+// getPlanChanges compares the deployed function, mapped to params, with the params to deploy; labels are only added or updated, since deploying doesn't remove labels
+func getPlanChanges(current Params, currentLabels map[string]string, desired Params, desiredLabels map[string]string) []PlanChange {
+
+	changes := []PlanChange{}
+	addChange := func(field, currentValue, desiredValue, risk string) {
+		if currentValue != desiredValue {
+			changes = append(changes, PlanChange{Field: field, Current: currentValue, Desired: desiredValue, Risk: risk})
+		}
+	}
+
+	addChange("generation", fmt.Sprint(current.Generation), fmt.Sprint(desired.Generation), "forces recreate")
+	triggerRisk := ""
+	if isTriggerTypeChange(current, desired) {
+		triggerRisk = "forces recreate"
+	}
+	addChange("trigger", current.Trigger, desired.Trigger, triggerRisk)
+	if current.Trigger == desired.Trigger {
+		addChange("trigger", getTriggerDescription(current), getTriggerDescription(desired), "")
+	}
+	addChange("runtime", current.Runtime, desired.Runtime, "")
+	if desired.EntryPoint != "" {
+		addChange("entryPoint", current.EntryPoint, desired.EntryPoint, "")
+	}
+	addChange("memory", current.Memory, desired.Memory, "")
+	addChange("timeout", fmt.Sprint(current.TimeoutSeconds), fmt.Sprint(desired.TimeoutSeconds), "")
+
+	// unset scaling settings keep their current value when deploying, except for the minimum which defaults to 0
+	if desired.CPU != "" {
+		addChange("cpu", current.CPU, desired.CPU, "")
+	}
+	if desired.Concurrency > 0 {
+		addChange("concurrency", fmt.Sprint(current.Concurrency), fmt.Sprint(desired.Concurrency), "")
+	}
+	addChange("minInstances", fmt.Sprint(current.MinInstances), fmt.Sprint(desired.MinInstances), "")
+	if desired.MaxInstances > 0 {
+		addChange("maxInstances", fmt.Sprint(current.MaxInstances), fmt.Sprint(desired.MaxInstances), "")
+	}
+
+	ingressRisk := ""
+	if desired.IngressSettings == "all" {
+		ingressRisk = "exposes function to the internet"
+	}
+	addChange("ingressSettings", current.IngressSettings, desired.IngressSettings, ingressRisk)
+	if desired.VPCConnector != "" || current.VPCConnector != "" {
+		addChange("vpcConnector", current.VPCConnector, desired.VPCConnector, "")
+		addChange("egressSettings", current.EgressSettings, desired.EgressSettings, "")
+	}
+
+	if desired.ServiceAccount != "" {
+		addChange("serviceAccount", current.ServiceAccount, desired.ServiceAccount, "")
+	}
+
+	// gcloud only grants public access, it doesn't revoke it
+	if desired.AllowUnauthenticated && !current.AllowUnauthenticated {
+		addChange("allowUnauthenticated", "false", "true", "makes function public")
+	}
+
+	// a local source is uploaded on every deployment, so only an archive can be compared
+	if strings.HasPrefix(desired.Source, "gs://") {
+		addChange("source", current.Source, desired.Source, "")
+	}
+
+	added, removed, changed := getEnvironmentVariableChanges(current.EnvironmentVariables, getDesiredEnvironmentVariables(current.EnvironmentVariables, desired))
+	for _, k := range added {
+		addChange("env."+k, "(unset)", "(set)", "")
+	}
+	for _, k := range removed {
+		addChange("env."+k, "(set)", "(unset)", "")
+	}
+	for _, k := range changed {
+		changes = append(changes, PlanChange{Field: "env." + k, Current: "(set)", Desired: "(changed)"})
+	}
+
+	for _, k := range sortedKeys(desiredLabels) {
+		currentValue, ok := currentLabels[k]
+		if !ok {
+			currentValue = "(unset)"
+		}
+		addChange("labels."+k, currentValue, desiredLabels[k], "")
+	}
+
+	return changes
+}
+
+// withoutLabels returns a copy of the labels without the keys, to leave labels that change with every run out of a comparison
+func withoutLabels(labels map[string]string, keys ...string) map[string]string {
+	copied := map[string]string{}
+	for k, v := range labels {
+		if !inStringArray(k, keys) {
+			copied[k] = v
+		}
+	}
+	return copied
+}
+
+// describeLiveParams retrieves the deployed function and maps it to params, including whether it allows unauthenticated invocations
+func describeLiveParams(ctx context.Context, params Params, credential GKECredentials) (*CloudFunction, Params, error) {
+
+	region := credential.AdditionalProperties.Region
+
+	function, err := describeLiveFunction(ctx, params.App, region, params.Generation)
+	if err != nil {
+		return nil, Params{}, err
+	}
+
+	current := function.ToParams()
+	if policy, err := getIAMPolicy(ctx, params.App, region, current.Generation); err == nil {
+		current.AllowUnauthenticated = policy.AllowsUnauthenticated()
+	}
+
+	return function, current, nil
+}
+
+// planFunction prints the differences between the deployed function and the params; it returns true if deploying would change the function
+func planFunction(ctx context.Context, params Params, credential GKECredentials, labels map[string]string) bool {
+
+	function, current, err := describeLiveParams(ctx, params, credential)
+	if err != nil {
+		if !isNotFoundError(err) {
+			log.Fatal().Err(err).Msgf("Failed retrieving cloud function %v to plan the changes", params.App)
+		}
+		log.Info().Msgf("Plan: cloud function %v doesn't exist and will be created", params.App)
+		return true
+	}
+
+	// the expiry of a preview moves with every deployment
+	changes := getPlanChanges(current, function.Labels, params, withoutLabels(labels, previewExpiresLabel))
+	if len(changes) == 0 {
+		log.Info().Msgf("Plan: cloud function %v is up to date", params.App)
+		return false
+	}
+
+	log.Info().Msgf("Plan: cloud function %v will be updated with %v changes", params.App, len(changes))
+	for _, change := range changes {
+		log.Info().Msgf("  %v", change)
+	}
+
+	return true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPlanChanges(t *testing.T) {
+	t.Run("ReturnsNoChangesIfFunctionMatchesParams", func(t *testing.T) {
+
+		current := validParams
+		current.EnvironmentVariables = map[string]interface{}{"KEY": "value"}
+		desired := validParams
+		desired.EnvironmentVariables = map[string]interface{}{"KEY": "value"}
+
+		// act
+		changes := getPlanChanges(current, map[string]string{"app": "myfunction"}, desired, map[string]string{"app": "myfunction"})
+
+		assert.Equal(t, 0, len(changes))
+	})
+
+	t.Run("ReturnsChangedFields", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+		desired.Memory = "512MB"
+		desired.Runtime = "go113"
+
+		// act
+		changes := getPlanChanges(current, map[string]string{}, desired, map[string]string{})
+
+		assert.Equal(t, []PlanChange{
+			{Field: "runtime", Current: "go111", Desired: "go113"},
+			{Field: "memory", Current: "256MB", Desired: "512MB"},
+		}, changes)
+	})
+
+	t.Run("FlagsTriggerTypeChangeAsForcingRecreate", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+		desired.Trigger = "topic"
+		desired.TriggerValue = "mytopic"
+
+		// act
+		changes := getPlanChanges(current, map[string]string{}, desired, map[string]string{})
+
+		if assert.Equal(t, 1, len(changes)) {
+			assert.Equal(t, "trigger", changes[0].Field)
+			assert.Equal(t, "forces recreate", changes[0].Risk)
+		}
+	})
+
+	t.Run("FlagsAllowUnauthenticatedAsRisky", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+		desired.AllowUnauthenticated = true
+
+		// act
+		changes := getPlanChanges(current, map[string]string{}, desired, map[string]string{})
+
+		if assert.Equal(t, 1, len(changes)) {
+			assert.Equal(t, "allowUnauthenticated", changes[0].Field)
+			assert.Equal(t, "makes function public", changes[0].Risk)
+		}
+	})
+
+	t.Run("ReturnsEnvironmentVariableKeysWithoutValues", func(t *testing.T) {
+
+		current := validParams
+		current.EnvironmentVariables = map[string]interface{}{"OLD": "value", "TOKEN": "secret"}
+		desired := validParams
+		desired.EnvironmentVariables = map[string]interface{}{"NEW": "value", "TOKEN": "other-secret"}
+
+		// act
+		changes := getPlanChanges(current, map[string]string{}, desired, map[string]string{})
+
+		assert.Equal(t, []PlanChange{
+			{Field: "env.NEW", Current: "(unset)", Desired: "(set)"},
+			{Field: "env.OLD", Current: "(set)", Desired: "(unset)"},
+			{Field: "env.TOKEN", Current: "(set)", Desired: "(changed)"},
+		}, changes)
+	})
+
+	t.Run("KeepsCurrentEnvironmentVariablesForEnvironmentModeMerge", func(t *testing.T) {
+
+		current := validParams
+		current.EnvironmentVariables = map[string]interface{}{"OLD": "value"}
+		desired := validParams
+		desired.EnvironmentMode = "merge"
+		desired.EnvironmentVariables = map[string]interface{}{"NEW": "value"}
+
+		// act
+		changes := getPlanChanges(current, map[string]string{}, desired, map[string]string{})
+
+		assert.Equal(t, []PlanChange{
+			{Field: "env.NEW", Current: "(unset)", Desired: "(set)"},
+		}, changes)
+	})
+
+	t.Run("KeepsCurrentEnvironmentVariablesForEnvironmentModeReplaceWithoutEnvironmentVariables", func(t *testing.T) {
+
+		current := validParams
+		current.EnvironmentVariables = map[string]interface{}{"OLD": "value"}
+		desired := validParams
+		desired.EnvironmentMode = "replace"
+		desired.EnvironmentVariables = nil
+
+		// act
+		changes := getPlanChanges(current, map[string]string{}, desired, map[string]string{})
+
+		assert.Equal(t, 0, len(changes))
+	})
+}
+
+func TestWithoutLabels(t *testing.T) {
+	t.Run("ReturnsCopyWithoutKeys", func(t *testing.T) {
+
+		labels := map[string]string{"app": "myfunction", "preview-expires": "1700000000"}
+
+		// act
+		copied := withoutLabels(labels, previewExpiresLabel)
+
+		assert.Equal(t, map[string]string{"app": "myfunction"}, copied)
+		assert.Equal(t, 2, len(labels))
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	previewAppLabel     = "preview-app"
+	previewBranchLabel  = "preview-branch"
+	previewExpiresLabel = "preview-expires"
+)
+
+// getPreviewLabels returns the labels that mark a function as preview of the app and tell when it can be cleaned up
+func getPreviewLabels(preview *PreviewParam, now time.Time) map[string]string {
+	return map[string]string{
+		previewAppLabel:     sanitizeLabel(preview.App),
+		previewBranchLabel:  sanitizeLabel(preview.GetSlug()),
+		previewExpiresLabel: strconv.FormatInt(preview.GetExpiry(now).Unix(), 10),
+	}
+}
+
+// isPreviewExpired returns true if the expiry label of the preview has passed; previews without a valid expiry label are left alone
+func isPreviewExpired(function *CloudFunction, now time.Time) bool {
+	expires, err := strconv.ParseInt(function.Labels[previewExpiresLabel], 10, 64)
+	if err != nil {
+		return false
+	}
+	return now.Unix() > expires
+}
+
+// listBranchSlugs returns the slugs of the branches of the cloned repository's remote
+func listBranchSlugs(ctx context.Context) (map[string]bool, error) {
+
+	output, err := getCommandWithArgsOutput(ctx, "git", []string{"ls-remote", "--heads", "origin"})
+	if err != nil {
+		return nil, err
+	}
+
+	slugs := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "refs/heads/") {
+			continue
+		}
+		slugs[sanitizeLabel(getBranchSlug(strings.TrimPrefix(fields[1], "refs/heads/")))] = true
+	}
+
+	return slugs, nil
+}
+
+// cleanupPreviews deletes the previews of the app whose ttl has expired or whose branch no longer exists
+func cleanupPreviews(ctx context.Context, params Params, credential GKECredentials, labels map[string]string) {
+
+	region := credential.AdditionalProperties.Region
+
+	log.Info().Msgf("Listing previews of cloud function %v...", params.App)
+	previews, err := listFunctions(ctx, region, map[string]string{previewAppLabel: sanitizeLabel(params.App)})
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed listing previews of cloud function %v", params.App)
+	}
+
+	branchSlugs, err := listBranchSlugs(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed listing branches, only cleaning up expired previews")
+	}
+
+	now := time.Now()
+	for _, preview := range previews {
+
+		name := preview.Name[strings.LastIndex(preview.Name, "/")+1:]
+		branch := preview.Labels[previewBranchLabel]
+
+		reason := ""
+		switch {
+		case isPreviewExpired(&preview, now):
+			reason = "its ttl has expired"
+		case branchSlugs != nil && !branchSlugs[branch]:
+			reason = fmt.Sprintf("branch %v no longer exists", branch)
+		default:
+			log.Info().Msgf("Keeping preview %v of branch %v", name, branch)
+			continue
+		}
+
+		log.Info().Msgf("Cleaning up preview %v since %v...", name, reason)
+
+		deleteFunction(ctx, getListedFunctionParams(params, &preview), credential, labels)
+	}
+}
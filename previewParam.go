@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PreviewParam is used to deploy a short-lived copy of the function per git branch
+type PreviewParam struct {
+	Branch string `json:"branch,omitempty"`
+	TTL    string `json:"ttl,omitempty"`
+
+	// App is the name of the function the preview is a copy of
+	App string `json:"-"`
+}
+
+// SetDefaults fills in empty fields with convention-based defaults
+func (p *PreviewParam) SetDefaults(gitBranch string) {
+
+	// default branch to the branch being built
+	if p.Branch == "" {
+		p.Branch = gitBranch
+	}
+
+	// default ttl to a week
+	if p.TTL == "" {
+		p.TTL = "168h"
+	}
+}
+
+// ValidateRequiredProperties checks whether all needed properties are set
+func (p *PreviewParam) ValidateRequiredProperties() (bool, []error) {
+
+	errors := []error{}
+
+	if p.GetSlug() == "" {
+		errors = append(errors, fmt.Errorf("Preview branch %v has no characters to name the function after; set preview.branch or run in an estafette build", p.Branch))
+	}
+
+	if ttl, err := time.ParseDuration(p.TTL); err != nil || ttl <= 0 {
+		errors = append(errors, fmt.Errorf("Preview ttl %v is not a valid duration; set it to a duration like 72h", p.TTL))
+	}
+
+	return len(errors) == 0, errors
+}
+
+// GetSlug returns the branch as lowercase letters, digits and single hyphens
+func (p *PreviewParam) GetSlug() string {
+	return getBranchSlug(p.Branch)
+}
+
+// GetExpiry returns the time after which the preview can be cleaned up
+func (p *PreviewParam) GetExpiry(now time.Time) time.Time {
+	ttl, _ := time.ParseDuration(p.TTL)
+	return now.Add(ttl)
+}
+
+func getBranchSlug(branch string) string {
+	return strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(branch), "-"), "-")
+}
+
+// getPreviewName suffixes the app with the branch slug; if that exceeds the maximum length the slug is shortened and a hash of the branch is appended to keep names of similar branches apart
+func getPreviewName(app, branch string, maxLength int) string {
+
+	slug := getBranchSlug(branch)
+	name := fmt.Sprintf("%v-%v", app, slug)
+	if len(name) <= maxLength {
+		return name
+	}
+
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(branch)))[:6]
+	slugLength := maxLength - len(app) - len(hash) - 2
+	if slugLength <= 0 {
+		return fmt.Sprintf("%v-%v", app, hash)
+	}
+
+	return fmt.Sprintf("%v-%v-%v", app, strings.TrimRight(slug[:slugLength], "-"), hash)
+}
+
+// getMaxFunctionNameLength returns the maximum length of a function name; gen2 functions are backed by a cloud run service, which has a lower limit
+func getMaxFunctionNameLength(generation int) int {
+	if generation == 2 {
+		return 49
+	}
+	return 63
+}
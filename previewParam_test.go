@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewParamValidateRequiredProperties(t *testing.T) {
+	t.Run("ReturnsTrueIfBranchAndTTLAreValid", func(t *testing.T) {
+
+		params := PreviewParam{
+			Branch: "feature/add-login",
+			TTL:    "72h",
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfBranchHasNoValidCharacters", func(t *testing.T) {
+
+		params := PreviewParam{
+			Branch: "___",
+			TTL:    "72h",
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfTTLIsNotADuration", func(t *testing.T) {
+
+		params := PreviewParam{
+			Branch: "feature/add-login",
+			TTL:    "3 days",
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+}
+
+func TestGetPreviewName(t *testing.T) {
+	t.Run("ReturnsAppSuffixedWithBranchSlug", func(t *testing.T) {
+
+		// act
+		name := getPreviewName("myfunction", "feature/ADD_login", 63)
+
+		assert.Equal(t, "myfunction-feature-add-login", name)
+	})
+
+	t.Run("ReturnsShortenedSlugWithHashIfNameIsTooLong", func(t *testing.T) {
+
+		// act
+		name := getPreviewName("myfunction", "feature/a-very-long-branch-name-describing-everything-it-does", 49)
+
+		assert.Equal(t, 49, len(name))
+		assert.Regexp(t, `^myfunction-feature-a-very-long-branch-name-[0-9a-f]{6}$`, name)
+	})
+
+	t.Run("ReturnsDifferentNamesForLongBranchesWithSamePrefix", func(t *testing.T) {
+
+		// act
+		first := getPreviewName("myfunction", "feature/a-very-long-branch-name-describing-everything-it-does-1", 49)
+		second := getPreviewName("myfunction", "feature/a-very-long-branch-name-describing-everything-it-does-2", 49)
+
+		assert.NotEqual(t, first, second)
+	})
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	foundation "github.com/estafette/estafette-foundation"
+	"github.com/rs/zerolog/log"
+)
+
+// getPromoteParams returns the params to deploy the function from the source project with; only env, serviceAccount and vpcConnector are taken from the stage, the rest is copied from the deployed function
+func getPromoteParams(params Params, function *CloudFunction, sourceProject string) (Params, error) {
+
+	promoteParams := function.ToParams()
+	promoteParams.Action = params.Action
+	promoteParams.DryRun = params.DryRun
+	promoteParams.App = params.App
+	promoteParams.BuildVersion = function.Labels[buildVersionLabel]
+	promoteParams.Schedule = params.Schedule
+	promoteParams.SmokeTest = params.SmokeTest
+	promoteParams.TestInvocation = params.TestInvocation
+	promoteParams.LogScan = params.LogScan
+	promoteParams.Metrics = params.Metrics
+
+	if len(params.EnvironmentVariables) > 0 {
+		if promoteParams.EnvironmentVariables == nil {
+			promoteParams.EnvironmentVariables = map[string]interface{}{}
+		}
+		for k, v := range params.EnvironmentVariables {
+			promoteParams.EnvironmentVariables[k] = v
+		}
+		promoteParams.EnvironmentMode = "replace"
+	}
+
+	sourceProjectPrefix := fmt.Sprintf("projects/%v/", sourceProject)
+
+	if params.ServiceAccount != "" {
+		promoteParams.ServiceAccount = params.ServiceAccount
+	} else if isDefaultServiceAccount(promoteParams.ServiceAccount, sourceProject) {
+		promoteParams.ServiceAccount = ""
+	} else if isProjectServiceAccount(promoteParams.ServiceAccount, sourceProject) {
+		return promoteParams, fmt.Errorf("Service account %v belongs to project %v; set serviceAccount to the service account to use in this project", promoteParams.ServiceAccount, sourceProject)
+	}
+
+	if params.VPCConnector != "" {
+		promoteParams.VPCConnector = params.VPCConnector
+	} else if strings.HasPrefix(promoteParams.VPCConnector, sourceProjectPrefix) {
+		return promoteParams, fmt.Errorf("VPC connector %v belongs to project %v; set vpcConnector to the connector to use in this project", promoteParams.VPCConnector, sourceProject)
+	}
+
+	// secrets from the source project resolve to the secret with the same name in this project
+	for key, value := range promoteParams.Secrets {
+		reference, err := parseSecretReference(value)
+		if err != nil {
+			return promoteParams, err
+		}
+		if reference.Project == sourceProject {
+			reference.Project = ""
+			promoteParams.Secrets[key] = reference.String()
+		}
+	}
+
+	// build settings are specific to the project they're in
+	if strings.HasPrefix(promoteParams.BuildWorkerPool, sourceProjectPrefix) {
+		promoteParams.BuildWorkerPool = ""
+	}
+	if strings.HasPrefix(promoteParams.BuildServiceAccount, sourceProjectPrefix) {
+		promoteParams.BuildServiceAccount = ""
+	}
+
+	if isDefaultServiceAccount(promoteParams.TriggerServiceAccount, sourceProject) {
+		promoteParams.TriggerServiceAccount = ""
+	} else if isProjectServiceAccount(promoteParams.TriggerServiceAccount, sourceProject) {
+		return promoteParams, fmt.Errorf("Trigger service account %v belongs to project %v and can't be promoted", promoteParams.TriggerServiceAccount, sourceProject)
+	}
+
+	if strings.HasPrefix(promoteParams.TriggerResource, sourceProjectPrefix) {
+		return promoteParams, fmt.Errorf("Trigger resource %v belongs to project %v and can't be promoted", promoteParams.TriggerResource, sourceProject)
+	}
+
+	return promoteParams, nil
+}
+
+// getPromotedInvokers returns the invokers of the function in the source project to grant in this project; its runtime service account is granted again by the schedule if there is one, while other service accounts of the source project don't exist in this project
+func getPromotedInvokers(invokers []string, function *CloudFunction, sourceProject string) ([]string, error) {
+
+	promoted := []string{}
+	for _, member := range invokers {
+		email := strings.TrimPrefix(member, "serviceAccount:")
+		switch {
+		case email == function.GetServiceAccountEmail():
+		case strings.HasPrefix(member, "serviceAccount:") && (isDefaultServiceAccount(email, sourceProject) || isProjectServiceAccount(email, sourceProject)):
+			return nil, fmt.Errorf("Invoker %v belongs to project %v and can't be promoted; grant the equivalent service account of this project instead and remove it from the function in project %v", member, sourceProject, sourceProject)
+		default:
+			promoted = append(promoted, member)
+		}
+	}
+
+	return promoted, nil
+}
+
+// getInvokerChanges returns the invokers to grant and to revoke to go from the current to the desired invokers
+func getInvokerChanges(current, desired []string) (granted, revoked []string) {
+	granted, revoked = []string{}, []string{}
+	for _, member := range desired {
+		if !inStringArray(member, current) {
+			granted = append(granted, member)
+		}
+	}
+	for _, member := range current {
+		if !inStringArray(member, desired) {
+			revoked = append(revoked, member)
+		}
+	}
+	return
+}
+
+// isDefaultServiceAccount returns true for the app engine and compute engine default service accounts, which every project has
+func isDefaultServiceAccount(email, project string) bool {
+	return email == fmt.Sprintf("%v@appspot.gserviceaccount.com", project) || strings.HasSuffix(email, "-compute@developer.gserviceaccount.com")
+}
+
+func isProjectServiceAccount(email, project string) bool {
+	return strings.HasSuffix(email, fmt.Sprintf("@%v.iam.gserviceaccount.com", project))
+}
+
+// promoteFunction deploys the source and configuration of the function deployed with the source credential to the project of the credential
+func promoteFunction(ctx context.Context, params Params, credential GKECredentials, sourceCredential GKECredentials, labels map[string]string) {
+
+	email, err := credential.GetClientEmail()
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed reading credential %v", credential.Name)
+	}
+	sourceEmail, err := sourceCredential.GetClientEmail()
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed reading credential %v", sourceCredential.Name)
+	}
+
+	log.Info().Msgf("Authenticating to google cloud with credential %v...", sourceCredential.Name)
+	keyFile, err := ioutil.TempFile("", "source-key-file-*.json")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed writing service account keyfile")
+	}
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.Write([]byte(sourceCredential.AdditionalProperties.ServiceAccountKeyfile))
+	keyFile.Close()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed writing service account keyfile")
+	}
+	foundation.RunCommandWithArgs(ctx, "gcloud", []string{"auth", "activate-service-account", sourceEmail, "--key-file", keyFile.Name()})
+
+	// activating a service account makes it the active one, so switch back to the account deploying the function
+	foundation.RunCommandWithArgs(ctx, "gcloud", []string{"config", "set", "account", email})
+
+	sourceProject := sourceCredential.AdditionalProperties.Project
+	log.Info().Msgf("Retrieving cloud function %v from project %v...", params.App, sourceProject)
+	function, _, err := describeFunction(ctx, params.App, sourceCredential.AdditionalProperties.Region, params.Generation, "--project", sourceProject, "--account", sourceEmail)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed retrieving cloud function %v from project %v", params.App, sourceProject)
+	}
+
+	promoteParams, err := getPromoteParams(params, function, sourceProject)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed promoting cloud function %v from project %v", params.App, sourceProject)
+	}
+
+	sourceInvokers, err := getLiveInvokers(ctx, params, sourceCredential.AdditionalProperties.Region, function, "--project", sourceProject, "--account", sourceEmail)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed retrieving invokers of cloud function %v from project %v", params.App, sourceProject)
+	}
+	invokers, err := getPromotedInvokers(sourceInvokers, function, sourceProject)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed promoting cloud function %v from project %v", params.App, sourceProject)
+	}
+	promoteParams.AllowUnauthenticated = inStringArray("allUsers", invokers)
+
+	sourceDirectory, err := ioutil.TempDir("", "source")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed creating source directory")
+	}
+	defer os.RemoveAll(sourceDirectory)
+
+	log.Info().Msgf("Downloading source of cloud function %v from project %v...", params.App, sourceProject)
+	archive, err := ioutil.TempFile("", "source-*.zip")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed creating source archive")
+	}
+	defer os.Remove(archive.Name())
+	err = downloadFunctionSource(ctx, function, sourceEmail, archive)
+	archive.Close()
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed downloading source of cloud function %v", params.App)
+	}
+	err = extractSourceArchive(archive.Name(), sourceDirectory)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed extracting source of cloud function %v", params.App)
+	}
+	promoteParams.Source = sourceDirectory
+
+	guardOwnership(ctx, params, credential, labels)
+
+	deployLabels := function.GetUserLabels()
+	for k, v := range labels {
+		deployLabels[k] = v
+	}
+
+	baseline := readBaselineMetrics(ctx, promoteParams, credential)
+
+	log.Info().Msgf("Promoting cloud function %v version %v from project %v to project %v...", params.App, promoteParams.BuildVersion, sourceProject, credential.AdditionalProperties.Project)
+	promotedFunction, err := runDeploy(ctx, promoteParams, credential, nil, getLabelParams(deployLabels), nil)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed promoting cloud function %v", params.App)
+	}
+	if promotedFunction == nil {
+		return
+	}
+
+	promoteInvokers(ctx, promoteParams, credential.AdditionalProperties.Region, promotedFunction, append(invokers, getDesiredInvokers(promoteParams, promotedFunction)...))
+
+	applySchedule(ctx, promoteParams, credential.AdditionalProperties.Region, promotedFunction)
+
+	err = verifyDeployment(ctx, promoteParams, credential, promotedFunction, baseline)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Verification of cloud function %v failed", params.App)
+	}
+}
+
+// promoteInvokers makes the invokers of the promoted function the desired ones, so it's as public or private as the function it was promoted from
+func promoteInvokers(ctx context.Context, params Params, region string, function *CloudFunction, desired []string) {
+
+	current, err := getLiveInvokers(ctx, params, region, function)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed retrieving invokers of cloud function %v", params.App)
+	}
+
+	granted, revoked := getInvokerChanges(current, desired)
+	for _, member := range granted {
+		log.Info().Msgf("Granting %v invoker on cloud function %v...", member, params.App)
+		foundation.RunCommandWithArgs(ctx, "gcloud", getInvokerArguments("add", params, region, member))
+	}
+	for _, member := range revoked {
+		log.Info().Msgf("Revoking invoker %v on cloud function %v, since the function it's promoted from doesn't grant it...", member, params.App)
+		foundation.RunCommandWithArgs(ctx, "gcloud", getInvokerArguments("remove", params, region, member))
+	}
+}
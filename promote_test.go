@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPromoteParams(t *testing.T) {
+
+	stagingFunction := CloudFunction{
+		Name:                 "projects/my-staging-project/locations/europe-west1/functions/myfunction",
+		Runtime:              "go113",
+		AvailableMemoryMb:    256,
+		Timeout:              "60s",
+		ServiceAccountEmail:  "myfunction@my-staging-project.iam.gserviceaccount.com",
+		VPCConnector:         "projects/my-staging-project/locations/europe-west1/connectors/myconnector",
+		EnvironmentVariables: map[string]string{"LOG_LEVEL": "debug", "API_URL": "https://api.staging.example.com"},
+		SecretEnvironmentVariables: []SecretEnvironmentVar{
+			{Key: "TOKEN", ProjectID: "my-staging-project", Secret: "token", Version: "latest"},
+		},
+		Labels: map[string]string{buildVersionLabel: "1-0-5"},
+	}
+
+	t.Run("ReturnsParamsWithOverridesAppliedToDeployedFunction", func(t *testing.T) {
+
+		params := validParams
+		params.EnvironmentVariables = map[string]interface{}{"API_URL": "https://api.example.com"}
+		params.ServiceAccount = "myfunction@my-project.iam.gserviceaccount.com"
+		params.VPCConnector = "myconnector"
+		params.Memory = "2048MB"
+
+		// act
+		promoteParams, err := getPromoteParams(params, &stagingFunction, "my-staging-project")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "go113", promoteParams.Runtime)
+		assert.Equal(t, "256MB", promoteParams.Memory)
+		assert.Equal(t, "1-0-5", promoteParams.BuildVersion)
+		assert.Equal(t, "debug", promoteParams.EnvironmentVariables["LOG_LEVEL"])
+		assert.Equal(t, "https://api.example.com", promoteParams.EnvironmentVariables["API_URL"])
+		assert.Equal(t, "myfunction@my-project.iam.gserviceaccount.com", promoteParams.ServiceAccount)
+		assert.Equal(t, "myconnector", promoteParams.VPCConnector)
+		assert.Equal(t, "token:latest", promoteParams.Secrets["TOKEN"])
+	})
+
+	t.Run("ReturnsErrorIfServiceAccountOfSourceProjectIsNotOverridden", func(t *testing.T) {
+
+		params := validParams
+		params.VPCConnector = "myconnector"
+
+		// act
+		_, err := getPromoteParams(params, &stagingFunction, "my-staging-project")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfVPCConnectorOfSourceProjectIsNotOverridden", func(t *testing.T) {
+
+		params := validParams
+		params.ServiceAccount = "myfunction@my-project.iam.gserviceaccount.com"
+
+		// act
+		_, err := getPromoteParams(params, &stagingFunction, "my-staging-project")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ClearsDefaultServiceAccountOfSourceProject", func(t *testing.T) {
+
+		function := stagingFunction
+		function.ServiceAccountEmail = "my-staging-project@appspot.gserviceaccount.com"
+		params := validParams
+		params.VPCConnector = "myconnector"
+
+		// act
+		promoteParams, err := getPromoteParams(params, &function, "my-staging-project")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "", promoteParams.ServiceAccount)
+	})
+}
+
+func TestGetPromotedInvokers(t *testing.T) {
+
+	stagingFunction := CloudFunction{
+		Name:                "projects/my-staging-project/locations/europe-west1/functions/myfunction",
+		ServiceAccountEmail: "myfunction@my-staging-project.iam.gserviceaccount.com",
+	}
+
+	t.Run("ReturnsPublicAccessAndUsersOfDeployedFunction", func(t *testing.T) {
+
+		invokers := []string{"allUsers", "group:developers@example.com"}
+
+		// act
+		promoted, err := getPromotedInvokers(invokers, &stagingFunction, "my-staging-project")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"allUsers", "group:developers@example.com"}, promoted)
+	})
+
+	t.Run("ReturnsNoInvokersForPrivateFunction", func(t *testing.T) {
+
+		// act
+		promoted, err := getPromotedInvokers([]string{}, &stagingFunction, "my-staging-project")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, promoted)
+	})
+
+	t.Run("SkipsRuntimeServiceAccountOfDeployedFunction", func(t *testing.T) {
+
+		invokers := []string{"serviceAccount:myfunction@my-staging-project.iam.gserviceaccount.com"}
+
+		// act
+		promoted, err := getPromotedInvokers(invokers, &stagingFunction, "my-staging-project")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, promoted)
+	})
+
+	t.Run("ReturnsErrorIfInvokerIsServiceAccountOfSourceProject", func(t *testing.T) {
+
+		invokers := []string{"serviceAccount:caller@my-staging-project.iam.gserviceaccount.com"}
+
+		// act
+		_, err := getPromotedInvokers(invokers, &stagingFunction, "my-staging-project")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetInvokerChanges(t *testing.T) {
+	t.Run("RevokesPublicAccessIfPromotedFunctionIsPrivate", func(t *testing.T) {
+
+		// act
+		granted, revoked := getInvokerChanges([]string{"allUsers"}, []string{})
+
+		assert.Equal(t, []string{}, granted)
+		assert.Equal(t, []string{"allUsers"}, revoked)
+	})
+
+	t.Run("GrantsMissingInvokersAndKeepsExistingOnes", func(t *testing.T) {
+
+		// act
+		granted, revoked := getInvokerChanges([]string{"group:developers@example.com"}, []string{"allUsers", "group:developers@example.com"})
+
+		assert.Equal(t, []string{"allUsers"}, granted)
+		assert.Equal(t, []string{}, revoked)
+	})
+}
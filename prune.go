@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// listFunctions returns the functions in the region that carry all of the labels
+func listFunctions(ctx context.Context, region string, labels map[string]string) ([]CloudFunction, error) {
+
+	filters := []string{}
+	for _, k := range sortedKeys(labels) {
+		filters = append(filters, fmt.Sprintf("labels.%v=%v", k, labels[k]))
+	}
+
+	output, err := getCommandWithArgsOutput(ctx, "gcloud", []string{
+		"functions", "list",
+		"--regions", region,
+		"--filter", strings.Join(filters, " AND "),
+		"--format", "json"})
+	if err != nil {
+		return nil, err
+	}
+
+	var functions []CloudFunction
+	err = json.Unmarshal([]byte(output), &functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return functions, nil
+}
+
+// getListedFunctionParams returns the params to delete a listed function with; topics and scheduler settings of the stage belong to the app, not to the listed function
+func getListedFunctionParams(params Params, function *CloudFunction) Params {
+
+	functionParams := params
+	functionParams.App = function.Name[strings.LastIndex(function.Name, "/")+1:]
+	functionParams.CreateTopic = false
+	functionParams.Generation = 1
+	if function.IsGen2() {
+		functionParams.Generation = 2
+	}
+
+	return functionParams
+}
+
+// getPrunableFunctions returns the functions that aren't part of the deployment set; previews are left to the cleanup-previews action
+func getPrunableFunctions(functions []CloudFunction, deploymentSet []string) []CloudFunction {
+
+	prunable := []CloudFunction{}
+	for _, function := range functions {
+		name := function.Name[strings.LastIndex(function.Name, "/")+1:]
+		if inStringArray(name, deploymentSet) {
+			continue
+		}
+		if _, ok := function.Labels[previewAppLabel]; ok {
+			continue
+		}
+		prunable = append(prunable, function)
+	}
+
+	return prunable
+}
+
+// pruneFunctions deletes the functions carrying the estafette labels of this pipeline that are no longer in the deployment set
+func pruneFunctions(ctx context.Context, params Params, credential GKECredentials, labels map[string]string) {
+
+	if len(labels) == 0 {
+		log.Fatal().Msg("There are no estafette labels to recognize the functions of this pipeline by; set labels in the manifest to prune functions")
+	}
+
+	region := credential.AdditionalProperties.Region
+
+	log.Info().Msgf("Listing cloud functions with labels %v in region %v...", strings.Join(getLabelParams(labels), ","), region)
+	functions, err := listFunctions(ctx, region, labels)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed listing cloud functions")
+	}
+
+	prunable := getPrunableFunctions(functions, params.Functions)
+
+	log.Info().Msgf("Deployment set: %v", strings.Join(params.Functions, ", "))
+	if len(prunable) == 0 {
+		log.Info().Msg("No cloud functions to prune")
+		return
+	}
+	for _, function := range prunable {
+		log.Info().Msgf("Would delete cloud function %v, last updated at %v", function.Name, function.UpdateTime)
+	}
+
+	if params.DryRun {
+		return
+	}
+
+	for _, function := range prunable {
+		deleteFunction(ctx, getListedFunctionParams(params, &function), credential, labels)
+	}
+}
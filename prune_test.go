@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPrunableFunctions(t *testing.T) {
+	t.Run("ReturnsFunctionsNotInDeploymentSet", func(t *testing.T) {
+
+		functions := []CloudFunction{
+			{Name: "projects/my-project/locations/europe-west1/functions/myfunction"},
+			{Name: "projects/my-project/locations/europe-west1/functions/myoldfunction"},
+		}
+
+		// act
+		prunable := getPrunableFunctions(functions, []string{"myfunction"})
+
+		if assert.Equal(t, 1, len(prunable)) {
+			assert.Equal(t, "projects/my-project/locations/europe-west1/functions/myoldfunction", prunable[0].Name)
+		}
+	})
+
+	t.Run("SkipsPreviews", func(t *testing.T) {
+
+		functions := []CloudFunction{
+			{Name: "projects/my-project/locations/europe-west1/functions/myfunction"},
+			{Name: "projects/my-project/locations/europe-west1/functions/myfunction-feature-x", Labels: map[string]string{previewAppLabel: "myfunction"}},
+		}
+
+		// act
+		prunable := getPrunableFunctions(functions, []string{"myfunction"})
+
+		assert.Equal(t, 0, len(prunable))
+	})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	foundation "github.com/estafette/estafette-foundation"
+	"github.com/rs/zerolog/log"
+)
+
+// isTriggerTypeChange returns true if the function switches between an http and an event trigger, which gcloud can't update in place
+func isTriggerTypeChange(current, desired Params) bool {
+	return (current.Trigger == "http") != (desired.Trigger == "http")
+}
+
+// getImmutableChanges explains the changes that can only be made by deleting and recreating the function
+func getImmutableChanges(current, desired Params) []string {
+
+	changes := []string{}
+	if current.Generation != desired.Generation {
+		changes = append(changes, fmt.Sprintf("generation changes from %v to %v", current.Generation, desired.Generation))
+	}
+	if isTriggerTypeChange(current, desired) {
+		changes = append(changes, fmt.Sprintf("trigger changes from %v to %v", current.Trigger, desired.Trigger))
+	}
+
+	return changes
+}
+
+// describeLiveFunction retrieves the deployed function of either generation, since the generation in the params may have changed; it only returns a not found error if the function exists in neither generation
+func describeLiveFunction(ctx context.Context, name, region string, generation int) (*CloudFunction, error) {
+
+	function, _, err := describeFunction(ctx, name, region, generation)
+	if err == nil || !isNotFoundError(err) {
+		return function, err
+	}
+
+	function, _, err = describeFunction(ctx, name, region, 3-generation)
+	if err == nil || !isNotFoundError(err) {
+		return function, err
+	}
+
+	return nil, err
+}
+
+// Recreation is the deletion of the deployed function that has to precede deploying it again, with the iam policy to restore once it's deployed
+type Recreation struct {
+	Function *CloudFunction
+	Changes  []string
+	Policy   RecreatedIAMPolicy
+	Labels   map[string]string
+}
+
+// RecreatedIAMPolicy is the iam policy of the deleted function mapped to the generation it's recreated with; invokers of a gen2 function are granted on its cloud run service
+type RecreatedIAMPolicy struct {
+	Bindings []IAMBinding
+	Invokers []string
+}
+
+// getRecreatedIAMPolicy maps the invoker role to the one of the generation; other cloud run roles have no equivalent on a function, so they can't be restored
+func getRecreatedIAMPolicy(policy *IAMPolicy, generation int) (RecreatedIAMPolicy, error) {
+
+	recreatedPolicy := RecreatedIAMPolicy{Bindings: []IAMBinding{}, Invokers: []string{}}
+	for _, binding := range policy.Bindings {
+		switch {
+		case binding.Role == gen1InvokerRole || binding.Role == gen2InvokerRole:
+			for _, member := range binding.Members {
+				if !inStringArray(member, recreatedPolicy.Invokers) {
+					recreatedPolicy.Invokers = append(recreatedPolicy.Invokers, member)
+				}
+			}
+		case strings.HasPrefix(binding.Role, "roles/run."):
+			return recreatedPolicy, fmt.Errorf("Role %v can't be restored on a generation %v function; remove the binding before recreating the function and grant it again afterwards", binding.Role, generation)
+		default:
+			recreatedPolicy.Bindings = append(recreatedPolicy.Bindings, binding)
+		}
+	}
+
+	if generation == 1 && len(recreatedPolicy.Invokers) > 0 {
+		recreatedPolicy.Bindings = append(recreatedPolicy.Bindings, IAMBinding{Role: gen1InvokerRole, Members: recreatedPolicy.Invokers})
+		recreatedPolicy.Invokers = []string{}
+	}
+
+	return recreatedPolicy, nil
+}
+
+// getRecreation returns the recreation needed if deploying the params can't update the function in place, or nil if it can; it doesn't delete anything, so all checks can run before the function is deleted right before deploying
+func getRecreation(ctx context.Context, params Params, credential GKECredentials, labels map[string]string) (*Recreation, error) {
+
+	region := credential.AdditionalProperties.Region
+
+	function, err := describeLiveFunction(ctx, params.App, region, params.Generation)
+	if err != nil {
+		if isNotFoundError(err) {
+			// the function doesn't exist yet
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Failed retrieving cloud function %v to check for immutable changes: %v", params.App, err)
+	}
+
+	current := function.ToParams()
+	changes := getImmutableChanges(current, params)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	if !params.AllowRecreate {
+		return nil, fmt.Errorf("Cloud function %v can't be updated in place since %v; set allowRecreate to true to delete and recreate it, which makes it unavailable in between", params.App, strings.Join(changes, " and "))
+	}
+
+	policy, err := getIAMPolicy(ctx, params.App, region, current.Generation)
+	if err != nil {
+		return nil, fmt.Errorf("Failed retrieving iam policy of cloud function %v to restore after recreating it: %v", params.App, err)
+	}
+
+	recreatedPolicy, err := getRecreatedIAMPolicy(policy, params.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recreation{Function: function, Changes: changes, Policy: recreatedPolicy, Labels: labels}, nil
+}
+
+// deleteForRecreation deletes the function to deploy it again
+func deleteForRecreation(ctx context.Context, params Params, credential GKECredentials, recreation *Recreation) {
+	log.Info().Msgf("Recreating cloud function %v since %v...", params.App, strings.Join(recreation.Changes, " and "))
+	deleteFunction(ctx, getListedFunctionParams(params, recreation.Function), credential, recreation.Labels)
+}
+
+// restoreIAMPolicy sets the bindings of the iam policy on the recreated function and grants the invokers of a gen2 function
+func restoreIAMPolicy(ctx context.Context, params Params, region string, policy RecreatedIAMPolicy) {
+
+	for _, member := range policy.Invokers {
+		log.Info().Msgf("Granting %v invoker on cloud function %v...", member, params.App)
+		foundation.RunCommandWithArgs(ctx, "gcloud", []string{"functions", "add-invoker-policy-binding", params.App, "--region", region, "--member", member})
+	}
+
+	if len(policy.Bindings) == 0 {
+		return
+	}
+
+	// the etag belongs to the deleted function, so only the bindings are restored
+	content, err := json.Marshal(IAMPolicy{Bindings: policy.Bindings})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed serializing iam policy")
+	}
+
+	file, err := ioutil.TempFile("", "iam-policy-*.json")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed writing iam policy file")
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	file.Close()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed writing iam policy file")
+	}
+
+	arguments := []string{"functions", "set-iam-policy", params.App, file.Name(), "--region", region}
+	if params.Generation == 2 {
+		arguments = append(arguments, "--gen2")
+	}
+
+	log.Info().Msgf("Restoring iam policy of cloud function %v...", params.App)
+	foundation.RunCommandWithArgs(ctx, "gcloud", arguments)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetImmutableChanges(t *testing.T) {
+	t.Run("ReturnsNoChangesIfTriggerStaysEventTrigger", func(t *testing.T) {
+
+		current := validParams
+		current.Trigger = "topic"
+		desired := validParams
+		desired.Trigger = "bucket"
+
+		// act
+		changes := getImmutableChanges(current, desired)
+
+		assert.Equal(t, 0, len(changes))
+	})
+
+	t.Run("ReturnsChangeIfTriggerSwitchesFromHttpToEvent", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+		desired.Trigger = "bucket"
+
+		// act
+		changes := getImmutableChanges(current, desired)
+
+		assert.Equal(t, []string{"trigger changes from http to bucket"}, changes)
+	})
+
+	t.Run("ReturnsChangeIfGenerationChanges", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+		desired.Generation = 2
+
+		// act
+		changes := getImmutableChanges(current, desired)
+
+		assert.Equal(t, []string{"generation changes from 1 to 2"}, changes)
+	})
+}
+
+func TestGetRecreatedIAMPolicy(t *testing.T) {
+	t.Run("GrantsGen1InvokersOnCloudRunServiceIfRecreatedAsGen2", func(t *testing.T) {
+
+		policy := &IAMPolicy{Bindings: []IAMBinding{
+			{Role: "roles/cloudfunctions.invoker", Members: []string{"allUsers"}},
+			{Role: "roles/cloudfunctions.viewer", Members: []string{"group:team@example.com"}},
+		}}
+
+		// act
+		recreatedPolicy, err := getRecreatedIAMPolicy(policy, 2)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []IAMBinding{{Role: "roles/cloudfunctions.viewer", Members: []string{"group:team@example.com"}}}, recreatedPolicy.Bindings)
+		assert.Equal(t, []string{"allUsers"}, recreatedPolicy.Invokers)
+	})
+
+	t.Run("MapsGen2InvokersToCloudFunctionsInvokerIfRecreatedAsGen1", func(t *testing.T) {
+
+		policy := &IAMPolicy{Bindings: []IAMBinding{
+			{Role: "roles/run.invoker", Members: []string{"serviceAccount:caller@my-project.iam.gserviceaccount.com"}},
+		}}
+
+		// act
+		recreatedPolicy, err := getRecreatedIAMPolicy(policy, 1)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []IAMBinding{{Role: "roles/cloudfunctions.invoker", Members: []string{"serviceAccount:caller@my-project.iam.gserviceaccount.com"}}}, recreatedPolicy.Bindings)
+		assert.Equal(t, 0, len(recreatedPolicy.Invokers))
+	})
+
+	t.Run("ReturnsErrorForCloudRunRoleOtherThanInvoker", func(t *testing.T) {
+
+		policy := &IAMPolicy{Bindings: []IAMBinding{
+			{Role: "roles/run.developer", Members: []string{"group:team@example.com"}},
+		}}
+
+		// act
+		_, err := getRecreatedIAMPolicy(policy, 1)
+
+		assert.NotNil(t, err)
+	})
+}
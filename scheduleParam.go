@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ScheduleParam is used to create a cloud scheduler job invoking an http function
+type ScheduleParam struct {
+	Cron       string              `json:"cron,omitempty"`
+	TimeZone   string              `json:"timeZone,omitempty"`
+	HTTPMethod string              `json:"httpMethod,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	Retry      *ScheduleRetryParam `json:"retry,omitempty"`
+}
+
+// ScheduleRetryParam configures how cloud scheduler retries a failed invocation
+type ScheduleRetryParam struct {
+	MaxRetryAttempts int    `json:"maxRetryAttempts,omitempty"`
+	MaxRetryDuration string `json:"maxRetryDuration,omitempty"`
+	MinBackoff       string `json:"minBackoff,omitempty"`
+	MaxBackoff       string `json:"maxBackoff,omitempty"`
+	MaxDoublings     int    `json:"maxDoublings,omitempty"`
+}
+
+// SetDefaults fills in empty fields with convention-based defaults
+func (p *ScheduleParam) SetDefaults() {
+
+	// default time zone to utc
+	if p.TimeZone == "" {
+		p.TimeZone = "Etc/UTC"
+	}
+
+	// default http method to post, like cloud scheduler does
+	if p.HTTPMethod == "" {
+		p.HTTPMethod = "POST"
+	}
+}
+
+// ValidateRequiredProperties checks whether all needed properties are set
+func (p *ScheduleParam) ValidateRequiredProperties() (bool, []error) {
+
+	errors := []error{}
+
+	// a cron expression has five fields, or is one of the app engine cron style schedules cloud scheduler accepts
+	if len(strings.Fields(p.Cron)) != 5 && !regexp.MustCompile(`^every [0-9]+ (minutes|hours)$`).MatchString(p.Cron) {
+		errors = append(errors, fmt.Errorf("Cron %v is not a valid cron expression; set it to a unix-cron expression like '0 * * * *'", p.Cron))
+	}
+
+	if !regexp.MustCompile(`^[A-Za-z]+(/[A-Za-z0-9_+-]+)*$`).MatchString(p.TimeZone) {
+		errors = append(errors, fmt.Errorf("TimeZone %v is not a valid time zone; set it to a tz database name like Europe/Amsterdam", p.TimeZone))
+	}
+
+	supportedHTTPMethods := []string{
+		"GET",
+		"POST",
+		"PUT",
+		"PATCH",
+		"DELETE",
+		"HEAD",
+		"OPTIONS",
+	}
+
+	if !inStringArray(p.HTTPMethod, supportedHTTPMethods) {
+		errors = append(errors, fmt.Errorf("HTTPMethod %v is not supported; set it to %v", p.HTTPMethod, strings.Join(supportedHTTPMethods, ", ")))
+	}
+
+	if p.Body != "" && !inStringArray(p.HTTPMethod, []string{"POST", "PUT", "PATCH"}) {
+		errors = append(errors, fmt.Errorf("Body can only be set for HTTPMethod POST, PUT or PATCH"))
+	}
+
+	if p.Retry != nil {
+		if p.Retry.MaxRetryAttempts < 0 || p.Retry.MaxRetryAttempts > 5 {
+			errors = append(errors, fmt.Errorf("MaxRetryAttempts %v is not supported; set it between 0 and 5", p.Retry.MaxRetryAttempts))
+		}
+		if p.Retry.MaxDoublings < 0 {
+			errors = append(errors, fmt.Errorf("MaxDoublings %v is not supported; set it to 0 or more", p.Retry.MaxDoublings))
+		}
+
+		durationReg := regexp.MustCompile(`^[0-9]+(s|m|h|d)$`)
+		for _, duration := range [][2]string{{"MaxRetryDuration", p.Retry.MaxRetryDuration}, {"MinBackoff", p.Retry.MinBackoff}, {"MaxBackoff", p.Retry.MaxBackoff}} {
+			if duration[1] != "" && !durationReg.MatchString(duration[1]) {
+				errors = append(errors, fmt.Errorf("%v %v is not a valid duration; set it to a value like 30s, 5m or 1h", duration[0], duration[1]))
+			}
+		}
+	}
+
+	return len(errors) == 0, errors
+}
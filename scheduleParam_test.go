@@ -0,0 +1,153 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	validScheduleParam = ScheduleParam{
+		Cron:       "0 * * * *",
+		TimeZone:   "Europe/Amsterdam",
+		HTTPMethod: "POST",
+	}
+)
+
+func TestScheduleParamSetDefaults(t *testing.T) {
+
+	t.Run("DefaultsTimeZoneToUTCIfEmpty", func(t *testing.T) {
+
+		params := ScheduleParam{
+			TimeZone: "",
+		}
+
+		// act
+		params.SetDefaults()
+
+		assert.Equal(t, "Etc/UTC", params.TimeZone)
+	})
+
+	t.Run("KeepsTimeZoneIfNotEmpty", func(t *testing.T) {
+
+		params := ScheduleParam{
+			TimeZone: "Europe/Amsterdam",
+		}
+
+		// act
+		params.SetDefaults()
+
+		assert.Equal(t, "Europe/Amsterdam", params.TimeZone)
+	})
+
+	t.Run("DefaultsHTTPMethodToPostIfEmpty", func(t *testing.T) {
+
+		params := ScheduleParam{
+			HTTPMethod: "",
+		}
+
+		// act
+		params.SetDefaults()
+
+		assert.Equal(t, "POST", params.HTTPMethod)
+	})
+}
+
+func TestScheduleParamValidateRequiredProperties(t *testing.T) {
+
+	t.Run("ReturnsFalseIfCronIsNotSet", func(t *testing.T) {
+
+		params := validScheduleParam
+		params.Cron = ""
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfCronDoesNotHaveFiveFields", func(t *testing.T) {
+
+		params := validScheduleParam
+		params.Cron = "0 * * *"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfHTTPMethodIsNotSupported", func(t *testing.T) {
+
+		params := validScheduleParam
+		params.HTTPMethod = "FETCH"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfBodyIsSetForHTTPMethodGet", func(t *testing.T) {
+
+		params := validScheduleParam
+		params.HTTPMethod = "GET"
+		params.Body = "{}"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfMaxRetryAttemptsIsLargerThan5", func(t *testing.T) {
+
+		params := validScheduleParam
+		params.Retry = &ScheduleRetryParam{
+			MaxRetryAttempts: 6,
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfMinBackoffIsNotAValidDuration", func(t *testing.T) {
+
+		params := validScheduleParam
+		params.Retry = &ScheduleRetryParam{
+			MinBackoff: "5 seconds",
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsTrueIfAllPropertiesAreValid", func(t *testing.T) {
+
+		params := validScheduleParam
+		params.Body = `{"full":true}`
+		params.Retry = &ScheduleRetryParam{
+			MaxRetryAttempts: 3,
+			MaxRetryDuration: "1h",
+			MinBackoff:       "5s",
+			MaxBackoff:       "1h",
+			MaxDoublings:     5,
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	foundation "github.com/estafette/estafette-foundation"
+	"github.com/rs/zerolog/log"
+)
+
+// schedulerJobDescriptionPrefix marks cloud scheduler jobs managed by this extension, so they can be cleaned up when the schedule is removed
+const schedulerJobDescriptionPrefix = "Managed by estafette-extension-cloud-function"
+
+// SchedulerJob represents the output of gcloud scheduler jobs describe --format json
+type SchedulerJob struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// IsManaged returns true if the job was created by this extension
+func (j *SchedulerJob) IsManaged() bool {
+	return strings.HasPrefix(j.Description, schedulerJobDescriptionPrefix)
+}
+
+func describeSchedulerJob(ctx context.Context, name, location string) *SchedulerJob {
+	output, err := getCommandWithArgsOutput(ctx, "gcloud", []string{"scheduler", "jobs", "describe", name, "--location", location, "--format", "json"})
+	if err != nil {
+		return nil
+	}
+
+	var job SchedulerJob
+	err = json.Unmarshal([]byte(output), &job)
+	if err != nil {
+		return nil
+	}
+
+	return &job
+}
+
+// applySchedule creates or updates the scheduler job for the function, or deletes the job it manages if no schedule is set
+func applySchedule(ctx context.Context, params Params, region string, function *CloudFunction) {
+
+	job := describeSchedulerJob(ctx, params.App, region)
+
+	if params.Schedule == nil {
+		if job != nil && job.IsManaged() {
+			log.Info().Msgf("Deleting scheduler job %v since schedule is no longer set...", params.App)
+			deleteSchedulerJob(ctx, params.App, region)
+		}
+		return
+	}
+
+	if job != nil && !job.IsManaged() {
+		log.Fatal().Msgf("Scheduler job %v already exists but isn't managed by this extension; delete it to have it recreated from the schedule parameter", params.App)
+	}
+
+	uri := function.GetURL()
+	if uri == "" {
+		log.Fatal().Msgf("Function %v has no https url to schedule", params.App)
+	}
+
+	serviceAccount := function.GetServiceAccountEmail()
+	if serviceAccount == "" {
+		log.Fatal().Msgf("Function %v has no runtime service account to sign oidc tokens with", params.App)
+	}
+
+	log.Info().Msgf("Granting %v invoker on cloud function %v for the scheduler job...", serviceAccount, params.App)
+	foundation.RunCommandWithArgs(ctx, "gcloud", getSchedulerInvokerArguments(params, region, serviceAccount))
+
+	action := "create"
+	if job != nil {
+		action = "update"
+	}
+
+	arguments := []string{
+		"scheduler", "jobs", action, "http", params.App,
+		"--location", region,
+		"--description", fmt.Sprintf("%v for function %v", schedulerJobDescriptionPrefix, params.App),
+		"--schedule", params.Schedule.Cron,
+		"--time-zone", params.Schedule.TimeZone,
+		"--uri", uri,
+		"--http-method", params.Schedule.HTTPMethod,
+		"--oidc-service-account-email", serviceAccount,
+		"--oidc-token-audience", uri,
+	}
+
+	if params.Schedule.Body != "" {
+		arguments = append(arguments, "--message-body", params.Schedule.Body)
+	} else if action == "update" {
+		arguments = append(arguments, "--clear-message-body")
+	}
+
+	if params.Schedule.Retry != nil {
+		arguments = append(arguments, "--max-retry-attempts", fmt.Sprintf("%v", params.Schedule.Retry.MaxRetryAttempts))
+		if params.Schedule.Retry.MaxRetryDuration != "" {
+			arguments = append(arguments, "--max-retry-duration", params.Schedule.Retry.MaxRetryDuration)
+		}
+		if params.Schedule.Retry.MinBackoff != "" {
+			arguments = append(arguments, "--min-backoff", params.Schedule.Retry.MinBackoff)
+		}
+		if params.Schedule.Retry.MaxBackoff != "" {
+			arguments = append(arguments, "--max-backoff", params.Schedule.Retry.MaxBackoff)
+		}
+		if params.Schedule.Retry.MaxDoublings > 0 {
+			arguments = append(arguments, "--max-doublings", fmt.Sprintf("%v", params.Schedule.Retry.MaxDoublings))
+		}
+	}
+
+	log.Info().Msgf("Applying scheduler job %v with schedule '%v'...", params.App, params.Schedule.Cron)
+	foundation.RunCommandWithArgs(ctx, "gcloud", arguments)
+}
+
+// getSchedulerInvokerArguments returns the arguments to let the service account the job signs its oidc token with invoke the function
+func getSchedulerInvokerArguments(params Params, region, serviceAccount string) []string {
+	return getInvokerArguments("add", params, region, "serviceAccount:"+serviceAccount)
+}
+
+func deleteSchedulerJob(ctx context.Context, name, location string) {
+	foundation.RunCommandWithArgs(ctx, "gcloud", []string{"scheduler", "jobs", "delete", name, "--location", location, "--quiet"})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSchedulerInvokerArguments(t *testing.T) {
+	t.Run("ReturnsCloudFunctionsInvokerBindingForGen1", func(t *testing.T) {
+
+		params := Params{App: "my-app", Generation: 1}
+
+		// act
+		arguments := getSchedulerInvokerArguments(params, "europe-west1", "my-app@my-project.iam.gserviceaccount.com")
+
+		assert.Equal(t, []string{"functions", "add-iam-policy-binding", "my-app", "--region", "europe-west1", "--member", "serviceAccount:my-app@my-project.iam.gserviceaccount.com", "--role", "roles/cloudfunctions.invoker"}, arguments)
+	})
+
+	t.Run("ReturnsInvokerPolicyBindingForGen2", func(t *testing.T) {
+
+		params := Params{App: "my-app", Generation: 2}
+
+		// act
+		arguments := getSchedulerInvokerArguments(params, "europe-west1", "my-app@my-project.iam.gserviceaccount.com")
+
+		assert.Equal(t, []string{"functions", "add-invoker-policy-binding", "my-app", "--region", "europe-west1", "--member", "serviceAccount:my-app@my-project.iam.gserviceaccount.com"}, arguments)
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	secretFullReferenceRegex  = regexp.MustCompile(`^projects/([^/:]+)/secrets/([a-zA-Z0-9_-]+)(?:(?:/versions/|:)(latest|[0-9]+))?$`)
+	secretShortReferenceRegex = regexp.MustCompile(`^([a-zA-Z0-9_-]+)(?::(latest|[0-9]+))?$`)
+	secretEnvVarNameRegex     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	secretMountPathRegex      = regexp.MustCompile(`^/[^:=,]+$`)
+)
+
+// SecretReference points at a version of a secret in Secret Manager
+type SecretReference struct {
+	Project string
+	Secret  string
+	Version string
+}
+
+// parseSecretReference accepts projects/{project}/secrets/{secret}/versions/{version}, projects/{project}/secrets/{secret}:{version} or the shorthand {secret}:{version}; the version defaults to latest
+func parseSecretReference(value string) (*SecretReference, error) {
+	if matches := secretFullReferenceRegex.FindStringSubmatch(value); matches != nil {
+		return &SecretReference{Project: matches[1], Secret: matches[2], Version: defaultSecretVersion(matches[3])}, nil
+	}
+	if matches := secretShortReferenceRegex.FindStringSubmatch(value); matches != nil {
+		return &SecretReference{Secret: matches[1], Version: defaultSecretVersion(matches[2])}, nil
+	}
+	return nil, fmt.Errorf("Secret reference %v is not valid; set it to projects/{project}/secrets/{secret}/versions/{version} or {secret}:{version}", value)
+}
+
+func defaultSecretVersion(version string) string {
+	if version == "" {
+		return "latest"
+	}
+	return version
+}
+
+// String returns the reference in the format expected by gcloud --set-secrets
+func (r SecretReference) String() string {
+	if r.Project != "" {
+		return fmt.Sprintf("projects/%v/secrets/%v:%v", r.Project, r.Secret, r.Version)
+	}
+	return fmt.Sprintf("%v:%v", r.Secret, r.Version)
+}
+
+func (p *Params) validateSecrets(project string) (errors []error) {
+	for _, key := range sortedKeys(p.Secrets) {
+		if !secretEnvVarNameRegex.MatchString(key) && !secretMountPathRegex.MatchString(key) {
+			errors = append(errors, fmt.Errorf("Secret %v is neither a valid environment variable name nor an absolute mount path", key))
+		}
+		if _, ok := p.EnvironmentVariables[key]; ok {
+			errors = append(errors, fmt.Errorf("Secret %v is also set as environment variable; remove it from env", key))
+		}
+
+		reference, err := parseSecretReference(p.Secrets[key])
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if reference.Project != "" && reference.Project != project && !p.AllowCrossProjectSecrets {
+			errors = append(errors, fmt.Errorf("Secret %v points at project %v instead of %v; set allowCrossProjectSecrets to true to allow this", key, reference.Project, project))
+		}
+	}
+
+	return errors
+}
+
+// getSecretArguments returns the key=reference pairs in a stable order to pass to --set-secrets
+func getSecretArguments(secrets map[string]string) string {
+	pairs := []string{}
+	for _, key := range sortedKeys(secrets) {
+		reference, err := parseSecretReference(secrets[key])
+		if err != nil {
+			continue
+		}
+		pairs = append(pairs, fmt.Sprintf("%v=%v", key, reference))
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSecretReference(t *testing.T) {
+	t.Run("ReturnsProjectSecretAndVersionOfFullReference", func(t *testing.T) {
+
+		// act
+		reference, err := parseSecretReference("projects/my-project/secrets/api-key/versions/3")
+
+		assert.Nil(t, err)
+		assert.Equal(t, SecretReference{Project: "my-project", Secret: "api-key", Version: "3"}, *reference)
+	})
+
+	t.Run("ReturnsProjectSecretAndVersionOfFullReferenceWithColon", func(t *testing.T) {
+
+		// act
+		reference, err := parseSecretReference("projects/my-project/secrets/api-key:3")
+
+		assert.Nil(t, err)
+		assert.Equal(t, SecretReference{Project: "my-project", Secret: "api-key", Version: "3"}, *reference)
+	})
+
+	t.Run("DefaultsVersionToLatestForFullReference", func(t *testing.T) {
+
+		// act
+		reference, err := parseSecretReference("projects/my-project/secrets/api-key")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "latest", reference.Version)
+	})
+
+	t.Run("DefaultsVersionToLatestForShorthand", func(t *testing.T) {
+
+		// act
+		reference, err := parseSecretReference("api-key")
+
+		assert.Nil(t, err)
+		assert.Equal(t, SecretReference{Secret: "api-key", Version: "latest"}, *reference)
+	})
+
+	t.Run("ReturnsErrorIfVersionIsNotANumberOrLatest", func(t *testing.T) {
+
+		// act
+		_, err := parseSecretReference("api-key:newest")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfFullReferenceHasEmptyVersion", func(t *testing.T) {
+
+		// act
+		_, err := parseSecretReference("projects/my-project/secrets/api-key/versions/")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfSecretNameHasInvalidCharacters", func(t *testing.T) {
+
+		// act
+		_, err := parseSecretReference("api key")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestValidateSecrets(t *testing.T) {
+	t.Run("ReturnsNoErrorsForEnvironmentVariableAndMountPath", func(t *testing.T) {
+
+		params := Params{Secrets: map[string]string{"API_KEY": "api-key:1", "/etc/secrets/cert.pem": "tls-cert"}}
+
+		// act
+		errors := params.validateSecrets("my-project")
+
+		assert.Equal(t, 0, len(errors))
+	})
+
+	t.Run("ReturnsErrorIfKeyIsNeitherEnvironmentVariableNorMountPath", func(t *testing.T) {
+
+		params := Params{Secrets: map[string]string{"api-key": "api-key:1"}}
+
+		// act
+		errors := params.validateSecrets("my-project")
+
+		assert.Equal(t, 1, len(errors))
+	})
+
+	t.Run("ReturnsErrorIfReferenceIsNotValid", func(t *testing.T) {
+
+		params := Params{Secrets: map[string]string{"API_KEY": "projects/my-project/secrets/api-key/versions/"}}
+
+		// act
+		errors := params.validateSecrets("my-project")
+
+		assert.Equal(t, 1, len(errors))
+	})
+
+	t.Run("ReturnsErrorIfSecretIsAlsoSetAsEnvironmentVariable", func(t *testing.T) {
+
+		params := Params{
+			Secrets:              map[string]string{"API_KEY": "api-key:1"},
+			EnvironmentVariables: map[string]interface{}{"API_KEY": "plain"},
+		}
+
+		// act
+		errors := params.validateSecrets("my-project")
+
+		assert.Equal(t, 1, len(errors))
+	})
+
+	t.Run("ReturnsErrorIfSecretPointsAtOtherProject", func(t *testing.T) {
+
+		params := Params{Secrets: map[string]string{"API_KEY": "projects/other-project/secrets/api-key/versions/latest"}}
+
+		// act
+		errors := params.validateSecrets("my-project")
+
+		assert.Equal(t, 1, len(errors))
+	})
+
+	t.Run("ReturnsNoErrorsIfSecretPointsAtOtherProjectAndCrossProjectSecretsAreAllowed", func(t *testing.T) {
+
+		params := Params{
+			Secrets:                  map[string]string{"API_KEY": "projects/other-project/secrets/api-key/versions/latest"},
+			AllowCrossProjectSecrets: true,
+		}
+
+		// act
+		errors := params.validateSecrets("my-project")
+
+		assert.Equal(t, 0, len(errors))
+	})
+}
+
+func TestGetSecretArguments(t *testing.T) {
+	t.Run("ReturnsSortedPairsWithVersionDefaultedToLatest", func(t *testing.T) {
+
+		secrets := map[string]string{
+			"DB_PASSWORD":           "projects/my-project/secrets/db-password/versions/3",
+			"API_KEY":               "api-key",
+			"/etc/secrets/cert.pem": "tls-cert:2",
+		}
+
+		// act
+		argument := getSecretArguments(secrets)
+
+		assert.Equal(t, "/etc/secrets/cert.pem=tls-cert:2,API_KEY=api-key:latest,DB_PASSWORD=projects/my-project/secrets/db-password:3", argument)
+	})
+
+	t.Run("SkipsInvalidReferences", func(t *testing.T) {
+
+		secrets := map[string]string{"API_KEY": "api-key:1", "BROKEN": "api key"}
+
+		// act
+		argument := getSecretArguments(secrets)
+
+		assert.Equal(t, "API_KEY=api-key:1", argument)
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxLoggedResponseBytes limits how much of the response body of a failed smoke test is logged
+const maxLoggedResponseBytes = 4096
+
+// smokeTestTimeout limits a smoke test request without a latency budget
+const smokeTestTimeout = time.Minute
+
+// SmokeTestResult is the response of the smoke test request
+type SmokeTestResult struct {
+	Status  int
+	Headers http.Header
+	Body    string
+	Latency time.Duration
+}
+
+// checkSmokeTestResult returns the assertions of the smoke test the response doesn't meet
+func checkSmokeTestResult(test *SmokeTestParam, result SmokeTestResult) []error {
+
+	errors := []error{}
+
+	if result.Status != test.ExpectedStatus {
+		errors = append(errors, fmt.Errorf("Status is %v instead of %v", result.Status, test.ExpectedStatus))
+	}
+
+	if test.BodyRegex != "" && !regexp.MustCompile(test.BodyRegex).MatchString(result.Body) {
+		errors = append(errors, fmt.Errorf("Body doesn't match %v", test.BodyRegex))
+	}
+
+	if budget, err := time.ParseDuration(test.LatencyBudget); err == nil && result.Latency > budget {
+		errors = append(errors, fmt.Errorf("Latency %v exceeds budget %v", result.Latency, budget))
+	}
+
+	return errors
+}
+
+// sendSmokeTestRequest sends the smoke test request to the function url, with an id token if the function requires authentication; it gives up once the latency budget is exceeded, so a hanging function fails the smoke test instead of blocking the release
+func sendSmokeTestRequest(ctx context.Context, test *SmokeTestParam, uri, idToken string) (SmokeTestResult, error) {
+
+	request, err := http.NewRequest(test.Method, strings.TrimSuffix(uri, "/")+test.Path, strings.NewReader(test.Body))
+	if err != nil {
+		return SmokeTestResult{}, err
+	}
+	for k, v := range test.Headers {
+		request.Header.Set(k, v)
+	}
+	if idToken != "" {
+		request.Header.Set("Authorization", "Bearer "+idToken)
+	}
+
+	client := &http.Client{Timeout: smokeTestTimeout}
+	if budget, err := time.ParseDuration(test.LatencyBudget); err == nil && budget > 0 {
+		client.Timeout = budget
+	}
+
+	start := time.Now()
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return SmokeTestResult{}, fmt.Errorf("No response within latency budget %v", client.Timeout)
+		}
+		return SmokeTestResult{}, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return SmokeTestResult{}, err
+	}
+
+	return SmokeTestResult{
+		Status:  response.StatusCode,
+		Headers: response.Header,
+		Body:    string(body),
+		Latency: time.Since(start),
+	}, nil
+}
+
+// runSmokeTest sends the smoke test request to the deployed function; it returns an error with the response attached if the response doesn't meet the assertions
+func runSmokeTest(ctx context.Context, params Params, credential GKECredentials, function *CloudFunction) error {
+
+	uri := function.GetURL()
+	if uri == "" {
+		return fmt.Errorf("Function %v has no https url to smoke test", params.App)
+	}
+
+	idToken := ""
+	if !params.AllowUnauthenticated {
+		var err error
+		idToken, err = getIDToken(ctx, credential.AdditionalProperties.ServiceAccountKeyfile, uri)
+		if err != nil {
+			return fmt.Errorf("Failed getting id token to smoke test function %v: %v", params.App, err)
+		}
+	}
+
+	log.Info().Msgf("Smoke testing cloud function %v with %v %v...", params.App, params.SmokeTest.Method, params.SmokeTest.Path)
+	result, err := sendSmokeTestRequest(ctx, params.SmokeTest, uri, idToken)
+	if err != nil {
+		return fmt.Errorf("Failed sending smoke test request to cloud function %v: %v", params.App, err)
+	}
+
+	errors := checkSmokeTestResult(params.SmokeTest, result)
+	if len(errors) == 0 {
+		log.Info().Msgf("Smoke test succeeded with status %v in %v", result.Status, result.Latency)
+		return nil
+	}
+
+	body := result.Body
+	if len(body) > maxLoggedResponseBytes {
+		body = body[:maxLoggedResponseBytes] + "..."
+	}
+	return fmt.Errorf("Smoke test of cloud function %v failed: %v\nResponse status %v in %v, headers %v, body:\n%v", params.App, errors, result.Status, result.Latency, result.Headers, body)
+}
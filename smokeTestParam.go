@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SmokeTestParam is used to send a request to an http function after deploying it and check the response
+type SmokeTestParam struct {
+	Path           string            `json:"path,omitempty"`
+	Method         string            `json:"method,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	ExpectedStatus int               `json:"expectedStatus,omitempty"`
+	BodyRegex      string            `json:"bodyRegex,omitempty"`
+	LatencyBudget  string            `json:"latencyBudget,omitempty"`
+}
+
+// SetDefaults fills in empty fields with convention-based defaults
+func (p *SmokeTestParam) SetDefaults() {
+
+	// default path to the root of the function
+	if p.Path == "" {
+		p.Path = "/"
+	}
+
+	// default method to get
+	if p.Method == "" {
+		p.Method = "GET"
+	}
+
+	// default expected status to ok
+	if p.ExpectedStatus == 0 {
+		p.ExpectedStatus = 200
+	}
+
+	// default latency budget to the time a cold start may take
+	if p.LatencyBudget == "" {
+		p.LatencyBudget = "10s"
+	}
+}
+
+// ValidateRequiredProperties checks whether all needed properties are set
+func (p *SmokeTestParam) ValidateRequiredProperties() (bool, []error) {
+
+	errors := []error{}
+
+	if !strings.HasPrefix(p.Path, "/") {
+		errors = append(errors, fmt.Errorf("Smoke test path %v is not valid; set it to a path starting with /", p.Path))
+	}
+
+	supportedMethods := []string{
+		"GET",
+		"POST",
+		"PUT",
+		"PATCH",
+		"DELETE",
+		"HEAD",
+		"OPTIONS",
+	}
+
+	if !inStringArray(p.Method, supportedMethods) {
+		errors = append(errors, fmt.Errorf("Smoke test method %v is not supported; set it to %v", p.Method, strings.Join(supportedMethods, ", ")))
+	}
+
+	if p.ExpectedStatus < 100 || p.ExpectedStatus > 599 {
+		errors = append(errors, fmt.Errorf("Smoke test expectedStatus %v is not a valid http status", p.ExpectedStatus))
+	}
+
+	if p.BodyRegex != "" {
+		if _, err := regexp.Compile(p.BodyRegex); err != nil {
+			errors = append(errors, fmt.Errorf("Smoke test bodyRegex %v is not a valid regular expression: %v", p.BodyRegex, err))
+		}
+	}
+
+	if budget, err := time.ParseDuration(p.LatencyBudget); err != nil || budget <= 0 {
+		errors = append(errors, fmt.Errorf("Smoke test latencyBudget %v is not a valid duration; set it to a duration like 2s", p.LatencyBudget))
+	}
+
+	return len(errors) == 0, errors
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmokeTestParamSetDefaults(t *testing.T) {
+	t.Run("DefaultsToGetRootExpectingStatusOK", func(t *testing.T) {
+
+		params := SmokeTestParam{}
+
+		// act
+		params.SetDefaults()
+
+		assert.Equal(t, "/", params.Path)
+		assert.Equal(t, "GET", params.Method)
+		assert.Equal(t, 200, params.ExpectedStatus)
+		assert.Equal(t, "10s", params.LatencyBudget)
+	})
+}
+
+func TestSmokeTestParamValidateRequiredProperties(t *testing.T) {
+
+	validSmokeTestParam := SmokeTestParam{
+		Path:           "/health",
+		Method:         "GET",
+		ExpectedStatus: 200,
+		LatencyBudget:  "2s",
+	}
+
+	t.Run("ReturnsTrueIfAllPropertiesAreValid", func(t *testing.T) {
+
+		params := validSmokeTestParam
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfPathDoesNotStartWithSlash", func(t *testing.T) {
+
+		params := validSmokeTestParam
+		params.Path = "health"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfBodyRegexIsNotValid", func(t *testing.T) {
+
+		params := validSmokeTestParam
+		params.BodyRegex = "(ok"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfLatencyBudgetIsNotADuration", func(t *testing.T) {
+
+		params := validSmokeTestParam
+		params.LatencyBudget = "2 seconds"
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSmokeTestResult(t *testing.T) {
+
+	test := SmokeTestParam{
+		ExpectedStatus: 200,
+		BodyRegex:      `"status":\s*"ok"`,
+		LatencyBudget:  "2s",
+	}
+
+	t.Run("ReturnsNoErrorsIfResponseMeetsAssertions", func(t *testing.T) {
+
+		// act
+		errors := checkSmokeTestResult(&test, SmokeTestResult{Status: 200, Body: `{"status": "ok"}`, Latency: time.Second})
+
+		assert.Equal(t, 0, len(errors))
+	})
+
+	t.Run("ReturnsErrorForEachFailedAssertion", func(t *testing.T) {
+
+		// act
+		errors := checkSmokeTestResult(&test, SmokeTestResult{Status: 500, Body: `{"status": "error"}`, Latency: 3 * time.Second})
+
+		assert.Equal(t, 3, len(errors))
+	})
+}
+
+func TestSendSmokeTestRequest(t *testing.T) {
+	t.Run("SendsRequestWithHeadersBodyAndIDToken", func(t *testing.T) {
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Equal(t, "POST", r.Method)
+			assert.Equal(t, "/health", r.URL.Path)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, "Bearer mytoken", r.Header.Get("Authorization"))
+			assert.Equal(t, `{"ping":true}`, string(body))
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("pong"))
+		}))
+		defer server.Close()
+
+		test := SmokeTestParam{
+			Path:    "/health",
+			Method:  "POST",
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Body:    `{"ping":true}`,
+		}
+
+		// act
+		result, err := sendSmokeTestRequest(context.Background(), &test, server.URL+"/", "mytoken")
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, result.Status)
+		assert.Equal(t, "pong", result.Body)
+	})
+
+	t.Run("ReturnsErrorIfFunctionDoesNotRespondWithinLatencyBudget", func(t *testing.T) {
+
+		done := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		}))
+		defer server.Close()
+		defer close(done)
+
+		test := SmokeTestParam{Path: "/", Method: "GET", LatencyBudget: "50ms"}
+
+		// act
+		_, err := sendSmokeTestRequest(context.Background(), &test, server.URL, "")
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	foundation "github.com/estafette/estafette-foundation"
+	"github.com/rs/zerolog/log"
+)
+
+// buildVersionLabel is set on every deployed function, so the snapshot taken before deploying over it can be stored under its version
+const buildVersionLabel = "build-version"
+
+// apiClient calls google apis and downloads source archives; its timeout keeps a hanging connection from blocking the release
+var apiClient = &http.Client{Timeout: 5 * time.Minute}
+
+// previousSnapshotKey is the last known-good snapshot; the one taken before the last deployment, unless that version failed verification when it was deployed
+const previousSnapshotKey = "previous"
+
+// FunctionSnapshot stores the configuration of a deployed function and a copy of its source, to roll back to
+type FunctionSnapshot struct {
+	App          string          `json:"app"`
+	BuildVersion string          `json:"buildVersion"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	Source       string          `json:"source"`
+	Function     json.RawMessage `json:"function"`
+}
+
+// getSnapshotKey returns the build version the function was deployed with, or its update time for functions deployed before it was labeled
+func getSnapshotKey(function *CloudFunction) string {
+	if version, ok := function.Labels[buildVersionLabel]; ok && version != "" {
+		return version
+	}
+	return strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(function.UpdateTime), "-"), "-")
+}
+
+func getSnapshotPath(bucket, app, key, extension string) string {
+	return fmt.Sprintf("gs://%v/%v/%v.%v", bucket, app, key, extension)
+}
+
+// getFailedMarkerPath returns the object marking a build version whose deployment failed verification, so its snapshot never becomes the known-good one
+func getFailedMarkerPath(bucket, app, key string) string {
+	return getSnapshotPath(bucket, app, key, "failed")
+}
+
+// getRollbackKey returns the snapshot to roll back to, the last known-good one unless a version is set
+func getRollbackKey(params Params) string {
+	if params.RollbackVersion != "" {
+		return sanitizeLabel(params.RollbackVersion)
+	}
+	return previousSnapshotKey
+}
+
+// snapshotFunction copies the source and configuration of the currently deployed function to the snapshot bucket and returns its key; a function that doesn't exist yet has nothing to snapshot, so the key is empty
+func snapshotFunction(ctx context.Context, params Params, credential GKECredentials) (string, error) {
+
+	function, output, err := describeFunction(ctx, params.App, credential.AdditionalProperties.Region, params.Generation)
+	if err != nil {
+		if isNotFoundError(err) {
+			log.Info().Msgf("Cloud function %v doesn't exist yet, skipping snapshot", params.App)
+			return "", nil
+		}
+		return "", fmt.Errorf("Failed retrieving cloud function %v: %v", params.App, err)
+	}
+
+	key := getSnapshotKey(function)
+	sourcePath := getSnapshotPath(params.SnapshotBucket, params.App, key, "zip")
+
+	log.Info().Msgf("Storing snapshot %v of cloud function %v in bucket %v...", key, params.App, params.SnapshotBucket)
+
+	err = copyFunctionSource(ctx, function, sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("Failed copying source of cloud function %v: %v", params.App, err)
+	}
+
+	snapshot := FunctionSnapshot{
+		App:          params.App,
+		BuildVersion: key,
+		CreatedAt:    time.Now().UTC(),
+		Source:       sourcePath,
+		Function:     json.RawMessage(output),
+	}
+
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	err = writeBucketObject(ctx, content, getSnapshotPath(params.SnapshotBucket, params.App, key, "json"))
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// writeBucketObject uploads the content to the path in cloud storage
+func writeBucketObject(ctx context.Context, content []byte, path string) error {
+
+	file, err := ioutil.TempFile("", "object-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	return foundation.RunCommandWithArgsExtended(ctx, "gsutil", []string{"cp", file.Name(), path})
+}
+
+// getKnownGoodSnapshotKey returns the snapshot to roll back to once the function is snapshotted before deploying: the new snapshot, unless there was nothing to snapshot or its version failed verification when it was deployed
+func getKnownGoodSnapshotKey(previousKey, snapshotKey string, snapshotFailed bool) string {
+	if snapshotKey == "" || snapshotFailed {
+		return previousKey
+	}
+	return snapshotKey
+}
+
+// markSnapshotKnownGood makes the snapshot taken before deploying the one to roll back to, whatever the outcome of the deployment, unless the version in the snapshot failed verification itself
+func markSnapshotKnownGood(ctx context.Context, params Params, key string) error {
+
+	failed := foundation.RunCommandWithArgsExtended(ctx, "gsutil", []string{"-q", "stat", getFailedMarkerPath(params.SnapshotBucket, params.App, key)}) == nil
+	if getKnownGoodSnapshotKey(previousSnapshotKey, key, failed) != key {
+		log.Warn().Msgf("Snapshot %v of cloud function %v failed verification when it was deployed, keeping the last known-good snapshot", key, params.App)
+		return nil
+	}
+
+	log.Info().Msgf("Marking snapshot %v of cloud function %v as last known-good...", key, params.App)
+	return foundation.RunCommandWithArgsExtended(ctx, "gsutil", []string{"cp", getSnapshotPath(params.SnapshotBucket, params.App, key, "json"), getSnapshotPath(params.SnapshotBucket, params.App, previousSnapshotKey, "json")})
+}
+
+// markDeploymentFailed records that the deployed function failed verification, so its snapshot isn't marked known-good when deploying over it
+func markDeploymentFailed(ctx context.Context, params Params, function *CloudFunction) error {
+	return writeBucketObject(ctx, []byte{}, getFailedMarkerPath(params.SnapshotBucket, params.App, getSnapshotKey(function)))
+}
+
+// clearDeploymentFailed removes the failed marker of a version that passed verification when deployed again
+func clearDeploymentFailed(ctx context.Context, params Params, function *CloudFunction) error {
+	markerPath := getFailedMarkerPath(params.SnapshotBucket, params.App, getSnapshotKey(function))
+	if foundation.RunCommandWithArgsExtended(ctx, "gsutil", []string{"-q", "stat", markerPath}) != nil {
+		return nil
+	}
+	return foundation.RunCommandWithArgsExtended(ctx, "gsutil", []string{"rm", markerPath})
+}
+
+// copyFunctionSource copies the deployed source archive to the destination; sources uploaded by gcloud for gen1 functions aren't readable from storage, so those are downloaded via the api
+func copyFunctionSource(ctx context.Context, function *CloudFunction, destination string) error {
+
+	source := function.GetSource()
+	if strings.HasPrefix(source, "gs://") {
+		return foundation.RunCommandWithArgsExtended(ctx, "gsutil", []string{"cp", source, destination})
+	}
+
+	file, err := ioutil.TempFile("", "source-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	err = downloadFunctionSource(ctx, function, "", file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	return foundation.RunCommandWithArgsExtended(ctx, "gsutil", []string{"cp", file.Name(), destination})
+}
+
+// getAccessToken returns an access token of the account, or of the active account if empty, to call google apis with
+func getAccessToken(ctx context.Context, account string) (string, error) {
+
+	arguments := []string{"auth", "print-access-token"}
+	if account != "" {
+		arguments = append(arguments, account)
+	}
+	token, err := getCommandWithArgsOutput(ctx, "gcloud", arguments)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+// downloadFunctionSource writes the deployed source archive of the function, using the access token of the account or the active account if empty
+func downloadFunctionSource(ctx context.Context, function *CloudFunction, account string, writer io.Writer) error {
+
+	token, err := getAccessToken(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	downloadURL := ""
+	if source := function.GetSource(); strings.HasPrefix(source, "gs://") {
+		bucketAndObject := strings.SplitN(strings.TrimPrefix(source, "gs://"), "/", 2)
+		if len(bucketAndObject) != 2 {
+			return fmt.Errorf("Source archive %v is not a valid object", source)
+		}
+		downloadURL = fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%v/o/%v?alt=media", bucketAndObject[0], url.PathEscape(bucketAndObject[1]))
+	} else {
+		apiVersion := "v1"
+		if function.IsGen2() {
+			apiVersion = "v2"
+		}
+
+		request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://cloudfunctions.googleapis.com/%v/%v:generateDownloadUrl", apiVersion, function.Name), strings.NewReader("{}"))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+		request.Header.Set("Content-Type", "application/json")
+
+		var generatedURL struct {
+			DownloadURL string `json:"downloadUrl"`
+		}
+		err = doJSONRequest(request.WithContext(ctx), &generatedURL)
+		if err != nil {
+			return err
+		}
+		downloadURL = generatedURL.DownloadURL
+	}
+
+	request, err := http.NewRequest(http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(downloadURL, "https://storage.googleapis.com/storage/") {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := apiClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Downloading source archive returned status %v", response.StatusCode)
+	}
+
+	_, err = io.Copy(writer, response.Body)
+	return err
+}
+
+func doJSONRequest(request *http.Request, result interface{}) error {
+	response, err := apiClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Request to %v returned status %v: %v", request.URL, response.StatusCode, string(body))
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+// loadSnapshot reads the snapshot stored under the key from the snapshot bucket
+func loadSnapshot(ctx context.Context, bucket, app, key string) (*FunctionSnapshot, error) {
+	output, err := getCommandWithArgsOutput(ctx, "gsutil", []string{"cat", getSnapshotPath(bucket, app, key, "json")})
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot FunctionSnapshot
+	err = json.Unmarshal([]byte(output), &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// getRollbackParams returns the params to redeploy the function from the snapshot with
+func getRollbackParams(params Params, snapshot *FunctionSnapshot) (Params, *CloudFunction, error) {
+
+	var function CloudFunction
+	err := json.Unmarshal(snapshot.Function, &function)
+	if err != nil {
+		return params, nil, err
+	}
+
+	rollbackParams := function.ToParams()
+	rollbackParams.Action = params.Action
+	rollbackParams.DryRun = params.DryRun
+	rollbackParams.App = params.App
+	rollbackParams.Source = snapshot.Source
+	rollbackParams.BuildVersion = snapshot.BuildVersion
+
+	return rollbackParams, &function, nil
+}
+
+// rollbackFunction redeploys the source and configuration of a snapshot, the one taken before the last deployment unless a version is set
+func rollbackFunction(ctx context.Context, params Params, credential GKECredentials) {
+
+	key := getRollbackKey(params)
+
+	log.Info().Msgf("Loading snapshot %v of cloud function %v from bucket %v...", key, params.App, params.SnapshotBucket)
+	snapshot, err := loadSnapshot(ctx, params.SnapshotBucket, params.App, key)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed loading snapshot %v of cloud function %v", key, params.App)
+	}
+
+	rollbackParams, function, err := getRollbackParams(params, snapshot)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed reading snapshot %v of cloud function %v", key, params.App)
+	}
+
+	err = verifySourceArchive(ctx, rollbackParams.Source)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed verifying source of snapshot %v of cloud function %v", key, params.App)
+	}
+
+	log.Info().Msgf("Rolling back cloud function %v to version %v...", params.App, snapshot.BuildVersion)
+
+	_, err = runDeploy(ctx, rollbackParams, credential, nil, getLabelParams(function.GetUserLabels()), getRestoreArguments(rollbackParams))
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed rolling back cloud function %v", params.App)
+	}
+}
+
+// getRestoreArguments returns the arguments to redeploy a function exactly as it was, restoring its labels instead of merging them with the current ones and clearing the settings it didn't have; the trigger is always sent as a whole, so it needs no clearing
+func getRestoreArguments(params Params) []string {
+	arguments := []string{"--clear-labels"}
+	if len(params.Secrets) == 0 {
+		arguments = append(arguments, "--clear-secrets")
+	}
+	if len(params.BuildEnvironmentVariables) == 0 {
+		arguments = append(arguments, "--clear-build-env-vars")
+	}
+	if params.BuildWorkerPool == "" {
+		arguments = append(arguments, "--clear-build-worker-pool")
+	}
+	if params.VPCConnector == "" {
+		arguments = append(arguments, "--clear-vpc-connector")
+	} else if params.EgressSettings == "private-ranges-only" {
+		// the default egress isn't passed when deploying, so it wouldn't replace all
+		arguments = append(arguments, "--egress-settings", params.EgressSettings)
+	}
+	if params.MinInstances == 0 {
+		arguments = append(arguments, "--clear-min-instances")
+	}
+	if params.MaxInstances == 0 {
+		arguments = append(arguments, "--clear-max-instances")
+	}
+	if params.Generation == 2 && params.Concurrency == 0 {
+		arguments = append(arguments, "--concurrency", "1")
+	}
+	return arguments
+}
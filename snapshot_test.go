@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSnapshotKey(t *testing.T) {
+	t.Run("ReturnsBuildVersionLabel", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{buildVersionLabel: "1-0-3"}, UpdateTime: "2020-03-04T10:11:12.345Z"}
+
+		// act
+		key := getSnapshotKey(&function)
+
+		assert.Equal(t, "1-0-3", key)
+	})
+
+	t.Run("ReturnsSanitizedUpdateTimeIfBuildVersionLabelIsMissing", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{"app": "myfunction"}, UpdateTime: "2020-03-04T10:11:12.345Z"}
+
+		// act
+		key := getSnapshotKey(&function)
+
+		assert.Equal(t, "2020-03-04t10-11-12-345z", key)
+	})
+
+	t.Run("ReturnsSanitizedUpdateTimeIfBuildVersionLabelIsEmpty", func(t *testing.T) {
+
+		function := CloudFunction{Labels: map[string]string{buildVersionLabel: ""}, UpdateTime: "2020-03-04T10:11:12Z"}
+
+		// act
+		key := getSnapshotKey(&function)
+
+		assert.Equal(t, "2020-03-04t10-11-12z", key)
+	})
+}
+
+func TestGetRollbackKey(t *testing.T) {
+	t.Run("ReturnsLastKnownGoodSnapshotIfRollbackVersionIsEmpty", func(t *testing.T) {
+
+		params := Params{}
+
+		// act
+		key := getRollbackKey(params)
+
+		assert.Equal(t, previousSnapshotKey, key)
+	})
+
+	t.Run("ReturnsSanitizedRollbackVersion", func(t *testing.T) {
+
+		params := Params{RollbackVersion: "1.0.3+build"}
+
+		// act
+		key := getRollbackKey(params)
+
+		assert.Equal(t, "1.0.3-build", key)
+	})
+}
+
+func TestGetRollbackParams(t *testing.T) {
+	t.Run("ReturnsConfigurationOfSnapshotInsteadOfManifest", func(t *testing.T) {
+
+		params := validParams
+		params.Action = "rollback"
+		params.Runtime = "go113"
+		params.EnvironmentVariables = map[string]interface{}{"KEY": "new"}
+		snapshot := &FunctionSnapshot{
+			App:          "myfunction",
+			BuildVersion: "1-0-2",
+			Source:       "gs://my-snapshots/myfunction/1-0-2.zip",
+			Function:     json.RawMessage(`{"name":"projects/my-project/locations/europe-west1/functions/myfunction","runtime":"go111","availableMemoryMb":256,"timeout":"60s","httpsTrigger":{"url":"https://europe-west1-my-project.cloudfunctions.net/myfunction"},"labels":{"app":"myfunction","build-version":"1-0-2"},"environmentVariables":{"KEY":"old"}}`),
+		}
+
+		// act
+		rollbackParams, function, err := getRollbackParams(params, snapshot)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "rollback", rollbackParams.Action)
+		assert.Equal(t, "myfunction", rollbackParams.App)
+		assert.Equal(t, "gs://my-snapshots/myfunction/1-0-2.zip", rollbackParams.Source)
+		assert.Equal(t, "1-0-2", rollbackParams.BuildVersion)
+		assert.Equal(t, "go111", rollbackParams.Runtime)
+		assert.Equal(t, "http", rollbackParams.Trigger)
+		assert.Equal(t, map[string]interface{}{"KEY": "old"}, rollbackParams.EnvironmentVariables)
+		assert.Equal(t, map[string]string{"app": "myfunction", "build-version": "1-0-2"}, function.GetUserLabels())
+	})
+
+	t.Run("ReturnsErrorIfSnapshotFunctionIsNotValid", func(t *testing.T) {
+
+		snapshot := &FunctionSnapshot{App: "myfunction", BuildVersion: "1-0-2", Source: "gs://my-snapshots/myfunction/1-0-2.zip", Function: json.RawMessage(`"myfunction"`)}
+
+		// act
+		_, _, err := getRollbackParams(validParams, snapshot)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetKnownGoodSnapshotKey(t *testing.T) {
+	t.Run("ReturnsLastVerifiedVersionAfterDeployingOverItFails", func(t *testing.T) {
+
+		failed := map[string]bool{}
+		previous := ""
+
+		// 1.0.1 is deployed as new function, so there's nothing to snapshot
+		previous = getKnownGoodSnapshotKey(previous, "", false)
+
+		// 1.0.2 is deployed over 1.0.1 and passes verification
+		previous = getKnownGoodSnapshotKey(previous, "1.0.1", failed["1.0.1"])
+
+		// act
+		// 1.0.3 is deployed over 1.0.2 and fails verification
+		previous = getKnownGoodSnapshotKey(previous, "1.0.2", failed["1.0.2"])
+		failed["1.0.3"] = true
+
+		assert.Equal(t, "1.0.2", previous)
+	})
+
+	t.Run("KeepsLastKnownGoodSnapshotIfSnapshottedVersionFailedVerification", func(t *testing.T) {
+
+		// act
+		// 1.0.4 is deployed over 1.0.3, which failed verification and wasn't rolled back
+		key := getKnownGoodSnapshotKey("1.0.2", "1.0.3", true)
+
+		assert.Equal(t, "1.0.2", key)
+	})
+
+	t.Run("KeepsLastKnownGoodSnapshotIfThereIsNothingToSnapshot", func(t *testing.T) {
+
+		// act
+		key := getKnownGoodSnapshotKey("1.0.2", "", false)
+
+		assert.Equal(t, "1.0.2", key)
+	})
+}
+
+func TestGetRestoreArguments(t *testing.T) {
+	t.Run("ClearsSettingsTheFunctionDoesNotHave", func(t *testing.T) {
+
+		// act
+		arguments := getRestoreArguments(Params{Generation: 1})
+
+		assert.Equal(t, []string{"--clear-labels", "--clear-secrets", "--clear-build-env-vars", "--clear-build-worker-pool", "--clear-vpc-connector", "--clear-min-instances", "--clear-max-instances"}, arguments)
+	})
+
+	t.Run("KeepsSettingsTheFunctionHas", func(t *testing.T) {
+
+		params := Params{
+			Generation:                1,
+			Secrets:                   map[string]string{"API_KEY": "api-key:latest"},
+			BuildEnvironmentVariables: map[string]interface{}{"GOPRIVATE": "github.com/myorg/*"},
+			BuildWorkerPool:           "projects/my-project/locations/europe-west1/workerPools/my-pool",
+			VPCConnector:              "my-connector",
+			EgressSettings:            "all",
+			MinInstances:              1,
+			MaxInstances:              10,
+		}
+
+		// act
+		arguments := getRestoreArguments(params)
+
+		assert.Equal(t, []string{"--clear-labels"}, arguments)
+	})
+
+	t.Run("RestoresDefaultEgressSettingsIfFunctionHasVPCConnector", func(t *testing.T) {
+
+		params := Params{Generation: 1, VPCConnector: "my-connector", EgressSettings: "private-ranges-only", MinInstances: 1, MaxInstances: 10}
+
+		// act
+		arguments := getRestoreArguments(params)
+
+		assert.Equal(t, []string{"--clear-labels", "--clear-secrets", "--clear-build-env-vars", "--clear-build-worker-pool", "--egress-settings", "private-ranges-only"}, arguments)
+	})
+
+	t.Run("RestoresDefaultConcurrencyForGen2FunctionWithoutConcurrency", func(t *testing.T) {
+
+		params := Params{Generation: 2, VPCConnector: "my-connector", EgressSettings: "all", MinInstances: 1, MaxInstances: 10}
+
+		// act
+		arguments := getRestoreArguments(params)
+
+		assert.Equal(t, []string{"--clear-labels", "--clear-secrets", "--clear-build-env-vars", "--clear-build-worker-pool", "--concurrency", "1"}, arguments)
+	})
+}
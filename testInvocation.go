@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// testInvocationLogPollInterval is the time between reading the logs while waiting for the log pattern
+const testInvocationLogPollInterval = 5 * time.Second
+
+// CallResult represents the output of gcloud functions call --format json
+type CallResult struct {
+	ExecutionID string `json:"executionId,omitempty"`
+	Result      string `json:"result,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// LogEntry represents a line of gcloud functions logs read --format json
+type LogEntry struct {
+	ExecutionID string `json:"execution_id,omitempty"`
+	Level       string `json:"level,omitempty"`
+	Log         string `json:"log,omitempty"`
+	TimeUTC     string `json:"time_utc,omitempty"`
+}
+
+// parseCallResult reads the output of gcloud functions call; gen2 functions return the response as is, so output that isn't a call result is taken as result
+func parseCallResult(output string) CallResult {
+	var result CallResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil || result.ExecutionID == "" && result.Result == "" && result.Error == "" {
+		return CallResult{Result: strings.TrimSpace(output)}
+	}
+	return result
+}
+
+// findLogPattern returns the first log line matching the pattern
+func findLogPattern(entries []LogEntry, pattern string) (string, bool) {
+	reg := regexp.MustCompile(pattern)
+	for _, entry := range entries {
+		if reg.MatchString(entry.Log) {
+			return entry.Log, true
+		}
+	}
+	return "", false
+}
+
+func readFunctionLogs(ctx context.Context, params Params, region, executionID string, start time.Time) ([]LogEntry, error) {
+
+	arguments := []string{
+		"functions", "logs", "read", params.App,
+		"--region", region,
+		"--start-time", start.UTC().Format(time.RFC3339),
+		"--limit", "1000",
+		"--format", "json"}
+	if executionID != "" {
+		arguments = append(arguments, "--execution-id", executionID)
+	}
+	if params.Generation == 2 {
+		arguments = append(arguments, "--gen2")
+	}
+
+	output, err := getCommandWithArgsOutput(ctx, "gcloud", arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LogEntry
+	err = json.Unmarshal([]byte(output), &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// testInvocationEventSource is the source of cloud events sent to eventarc triggered functions, which have no resource to derive it from
+const testInvocationEventSource = "//estafette-extension-cloud-function/test-invocation"
+
+// getCloudEvent wraps the data in a structured cloud event of the type the trigger of a gen2 function delivers
+func getCloudEvent(params Params, project, id string) map[string]interface{} {
+
+	event := map[string]interface{}{
+		"specversion":     "1.0",
+		"id":              id,
+		"datacontenttype": "application/json",
+		"data":            params.TestInvocation.Data,
+	}
+
+	switch params.Trigger {
+	case "topic":
+		topic := params.TriggerValue
+		if !strings.HasPrefix(topic, "projects/") {
+			topic = fmt.Sprintf("projects/%v/topics/%v", project, topic)
+		}
+		event["type"] = pubsubEventType
+		event["source"] = "//pubsub.googleapis.com/" + topic
+	case "bucket":
+		event["type"] = storageEventPrefix + "finalized"
+		event["source"] = "//storage.googleapis.com/projects/_/buckets/" + params.TriggerValue
+	case "event":
+		event["type"] = params.TriggerEvent
+		event["source"] = params.TriggerResource
+	case "eventarc":
+		event["source"] = testInvocationEventSource
+		// the filters other than type are matched on the attributes of the event
+		for k, v := range params.EventFilters {
+			if k == eventFilterTypeKey {
+				event["type"] = v
+				continue
+			}
+			event[strings.ToLower(k)] = v
+		}
+	}
+
+	return event
+}
+
+// getTestInvocationArguments returns the arguments for gcloud functions call; gen2 event functions only accept a structured cloud event
+func getTestInvocationArguments(params Params, region, project, id string) ([]string, error) {
+
+	arguments := []string{"functions", "call", params.App, "--region", region, "--format", "json"}
+
+	if params.Generation != 2 {
+		data, err := marshalJSON(params.TestInvocation.Data)
+		if err != nil {
+			return nil, err
+		}
+		return append(arguments, "--data", data), nil
+	}
+
+	event, err := marshalJSON(getCloudEvent(params, project, id))
+	if err != nil {
+		return nil, err
+	}
+	return append(arguments, "--gen2", "--cloud-event", event), nil
+}
+
+// runTestInvocation calls the deployed function with the payload; it returns an error if the call errors or the log pattern doesn't appear in time
+func runTestInvocation(ctx context.Context, params Params, credential GKECredentials) error {
+
+	region := credential.AdditionalProperties.Region
+
+	start := time.Now()
+	arguments, err := getTestInvocationArguments(params, region, credential.AdditionalProperties.Project, fmt.Sprintf("test-invocation-%v", start.UnixNano()))
+	if err != nil {
+		return fmt.Errorf("Failed serializing test invocation data: %v", err)
+	}
+
+	log.Info().Msgf("Test invoking cloud function %v...", params.App)
+	output, err := getCommandWithArgsOutput(ctx, "gcloud", arguments)
+	if err != nil {
+		return fmt.Errorf("Test invocation of cloud function %v failed: %v: %v", params.App, err, output)
+	}
+
+	result := parseCallResult(output)
+	if result.Error != "" {
+		return fmt.Errorf("Test invocation %v of cloud function %v returned error: %v", result.ExecutionID, params.App, result.Error)
+	}
+	log.Info().Msgf("Test invocation %v of cloud function %v returned: %v", result.ExecutionID, params.App, result.Result)
+
+	if params.TestInvocation.LogPattern == "" {
+		return nil
+	}
+
+	timeout, _ := time.ParseDuration(params.TestInvocation.Timeout)
+	deadline := start.Add(timeout)
+
+	log.Info().Msgf("Waiting up to %v for a log line matching %v...", timeout, params.TestInvocation.LogPattern)
+	for {
+		entries, err := readFunctionLogs(ctx, params, region, result.ExecutionID, start.Add(-time.Minute))
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed reading logs of cloud function %v", params.App)
+		} else if line, ok := findLogPattern(entries, params.TestInvocation.LogPattern); ok {
+			log.Info().Msgf("Found log line: %v", line)
+			return nil
+		}
+
+		if time.Now().Add(testInvocationLogPollInterval).After(deadline) {
+			break
+		}
+		time.Sleep(testInvocationLogPollInterval)
+	}
+
+	return fmt.Errorf("No log line of test invocation %v of cloud function %v matched %v within %v", result.ExecutionID, params.App, params.TestInvocation.LogPattern, timeout)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// TestInvocationParam is used to call an event function with a payload after deploying it
+type TestInvocationParam struct {
+	Data       interface{} `json:"data,omitempty"`
+	LogPattern string      `json:"logPattern,omitempty"`
+	Timeout    string      `json:"timeout,omitempty"`
+}
+
+// SetDefaults fills in empty fields with convention-based defaults
+func (p *TestInvocationParam) SetDefaults() {
+
+	// default data to an empty event
+	if p.Data == nil {
+		p.Data = map[string]interface{}{}
+	}
+
+	// default timeout to wait for the log pattern to a minute, since logs take a while to be ingested
+	if p.Timeout == "" {
+		p.Timeout = "60s"
+	}
+}
+
+// ValidateRequiredProperties checks whether all needed properties are set
+func (p *TestInvocationParam) ValidateRequiredProperties() (bool, []error) {
+
+	errors := []error{}
+
+	if p.LogPattern != "" {
+		if _, err := regexp.Compile(p.LogPattern); err != nil {
+			errors = append(errors, fmt.Errorf("Test invocation logPattern %v is not a valid regular expression: %v", p.LogPattern, err))
+		}
+	}
+
+	if timeout, err := time.ParseDuration(p.Timeout); err != nil || timeout <= 0 {
+		errors = append(errors, fmt.Errorf("Test invocation timeout %v is not a valid duration; set it to a duration like 60s", p.Timeout))
+	}
+
+	return len(errors) == 0, errors
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestInvocationParamValidateRequiredProperties(t *testing.T) {
+	t.Run("ReturnsTrueIfAllPropertiesAreValid", func(t *testing.T) {
+
+		params := TestInvocationParam{
+			Data:       map[string]interface{}{"bucket": "my-bucket", "name": "image.png"},
+			LogPattern: `Processed file .+`,
+			Timeout:    "60s",
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.True(t, valid)
+		assert.True(t, len(errors) == 0)
+	})
+
+	t.Run("ReturnsFalseIfLogPatternIsNotValid", func(t *testing.T) {
+
+		params := TestInvocationParam{
+			LogPattern: `(Processed`,
+			Timeout:    "60s",
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+
+	t.Run("ReturnsFalseIfTimeoutIsNotADuration", func(t *testing.T) {
+
+		params := TestInvocationParam{
+			Timeout: "a minute",
+		}
+
+		// act
+		valid, errors := params.ValidateRequiredProperties()
+
+		assert.False(t, valid)
+		assert.True(t, len(errors) > 0)
+	})
+}
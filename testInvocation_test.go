@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCallResult(t *testing.T) {
+	t.Run("ReturnsExecutionIDAndResultOfGeneration1Call", func(t *testing.T) {
+
+		// act
+		result := parseCallResult(`{"executionId": "abc123", "result": "OK"}`)
+
+		assert.Equal(t, CallResult{ExecutionID: "abc123", Result: "OK"}, result)
+	})
+
+	t.Run("ReturnsError", func(t *testing.T) {
+
+		// act
+		result := parseCallResult(`{"executionId": "abc123", "error": "crash"}`)
+
+		assert.Equal(t, "crash", result.Error)
+	})
+
+	t.Run("ReturnsOutputAsResultIfItIsNotACallResult", func(t *testing.T) {
+
+		// act
+		result := parseCallResult("OK\n")
+
+		assert.Equal(t, CallResult{Result: "OK"}, result)
+	})
+}
+
+func TestFindLogPattern(t *testing.T) {
+	t.Run("ReturnsFirstMatchingLine", func(t *testing.T) {
+
+		entries := []LogEntry{
+			{Log: "Function execution started"},
+			{Log: "Processed file uploads/image.png"},
+			{Log: "Function execution took 120 ms, finished with status: 'ok'"},
+		}
+
+		// act
+		line, ok := findLogPattern(entries, `Processed file .+\.png`)
+
+		assert.True(t, ok)
+		assert.Equal(t, "Processed file uploads/image.png", line)
+	})
+
+	t.Run("ReturnsFalseIfNoLineMatches", func(t *testing.T) {
+
+		entries := []LogEntry{
+			{Log: "Function execution started"},
+		}
+
+		// act
+		_, ok := findLogPattern(entries, `Processed file`)
+
+		assert.False(t, ok)
+	})
+}
+
+func TestGetTestInvocationArguments(t *testing.T) {
+	t.Run("ReturnsDataForGen1Function", func(t *testing.T) {
+
+		params := Params{App: "my-app", Generation: 1, Trigger: "topic", TriggerValue: "my-topic", TestInvocation: &TestInvocationParam{Data: map[string]interface{}{"data": "aGVsbG8="}}}
+
+		// act
+		arguments, err := getTestInvocationArguments(params, "europe-west1", "my-project", "test-invocation-1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"functions", "call", "my-app", "--region", "europe-west1", "--format", "json", "--data", `{"data":"aGVsbG8="}`}, arguments)
+	})
+
+	t.Run("ReturnsCloudEventOfTopicForGen2Function", func(t *testing.T) {
+
+		params := Params{App: "my-app", Generation: 2, Trigger: "topic", TriggerValue: "my-topic", TestInvocation: &TestInvocationParam{Data: map[string]interface{}{"message": map[string]interface{}{"data": "aGVsbG8="}}}}
+
+		// act
+		arguments, err := getTestInvocationArguments(params, "europe-west1", "my-project", "test-invocation-1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"functions", "call", "my-app", "--region", "europe-west1", "--format", "json", "--gen2", "--cloud-event",
+			`{"data":{"message":{"data":"aGVsbG8="}},"datacontenttype":"application/json","id":"test-invocation-1","source":"//pubsub.googleapis.com/projects/my-project/topics/my-topic","specversion":"1.0","type":"google.cloud.pubsub.topic.v1.messagePublished"}`}, arguments)
+	})
+
+	t.Run("ReturnsCloudEventOfBucketForGen2Function", func(t *testing.T) {
+
+		params := Params{App: "my-app", Generation: 2, Trigger: "bucket", TriggerValue: "my-bucket", TestInvocation: &TestInvocationParam{Data: map[string]interface{}{"name": "image.png"}}}
+
+		// act
+		arguments, err := getTestInvocationArguments(params, "europe-west1", "my-project", "test-invocation-1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, `{"data":{"name":"image.png"},"datacontenttype":"application/json","id":"test-invocation-1","source":"//storage.googleapis.com/projects/_/buckets/my-bucket","specversion":"1.0","type":"google.cloud.storage.object.v1.finalized"}`, arguments[len(arguments)-1])
+	})
+
+	t.Run("ReturnsCloudEventWithEventFilterAttributesForGen2EventarcFunction", func(t *testing.T) {
+
+		params := Params{App: "my-app", Generation: 2, Trigger: "eventarc", EventFilters: map[string]string{"type": "google.cloud.audit.log.v1.written", "serviceName": "storage.googleapis.com"}, TestInvocation: &TestInvocationParam{Data: map[string]interface{}{}}}
+
+		// act
+		arguments, err := getTestInvocationArguments(params, "europe-west1", "my-project", "test-invocation-1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, `{"data":{},"datacontenttype":"application/json","id":"test-invocation-1","servicename":"storage.googleapis.com","source":"//estafette-extension-cloud-function/test-invocation","specversion":"1.0","type":"google.cloud.audit.log.v1.written"}`, arguments[len(arguments)-1])
+	})
+}
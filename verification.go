@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// checkFunctionStatus returns an error if the deployed function isn't active; gen1 reports status, gen2 state
+func checkFunctionStatus(function *CloudFunction) error {
+
+	// gen2 functions report their status as state; describe doesn't always say which generation a function is, so fall back to the other field if one is empty
+	status := function.Status
+	if status == "" || (function.IsGen2() && function.State != "") {
+		status = function.State
+	}
+	if status != "ACTIVE" {
+		return fmt.Errorf("Cloud function %v has status %v instead of ACTIVE", function.Name, status)
+	}
+
+	return nil
+}
+
+// verifyDeployment runs the post-deploy checks on the deployed function and returns the first that fails; the metrics are compared with the baseline if not nil
+func verifyDeployment(ctx context.Context, params Params, credential GKECredentials, function *CloudFunction, baseline *FunctionMetrics) error {
+
+	log.Info().Msgf("Checking status of cloud function %v...", params.App)
+	err := checkFunctionStatus(function)
+	if err != nil {
+		return err
+	}
+
+	if params.SmokeTest != nil {
+		err = runSmokeTest(ctx, params, credential, function)
+		if err != nil {
+			return err
+		}
+	}
+
+	if params.TestInvocation != nil {
+		err = runTestInvocation(ctx, params, credential)
+		if err != nil {
+			return err
+		}
+	}
+
+	if params.LogScan != nil {
+		err = runLogScan(ctx, params, credential, function)
+		if err != nil {
+			return err
+		}
+	}
+
+	if params.Metrics != nil {
+		err = runMetricsVerification(ctx, params, credential, function, baseline)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
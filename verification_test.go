@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFunctionStatus(t *testing.T) {
+	t.Run("ReturnsNilIfGen1FunctionIsActive", func(t *testing.T) {
+
+		function := &CloudFunction{Name: "projects/my-project/locations/europe-west1/functions/my-app", Status: "ACTIVE"}
+
+		// act
+		err := checkFunctionStatus(function)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfGen1FunctionIsNotActive", func(t *testing.T) {
+
+		function := &CloudFunction{Name: "projects/my-project/locations/europe-west1/functions/my-app", Status: "OFFLINE"}
+
+		// act
+		err := checkFunctionStatus(function)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsNilIfGen2FunctionIsActive", func(t *testing.T) {
+
+		function := &CloudFunction{Name: "projects/my-project/locations/europe-west1/functions/my-app", Environment: "GEN_2", State: "ACTIVE"}
+
+		// act
+		err := checkFunctionStatus(function)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfGen2FunctionIsFailed", func(t *testing.T) {
+
+		function := &CloudFunction{Name: "projects/my-project/locations/europe-west1/functions/my-app", Environment: "GEN_2", State: "FAILED"}
+
+		// act
+		err := checkFunctionStatus(function)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsNilIfFunctionWithoutGenerationHasActiveState", func(t *testing.T) {
+
+		function := &CloudFunction{Name: "projects/my-project/locations/europe-west1/functions/my-app", State: "ACTIVE"}
+
+		// act
+		err := checkFunctionStatus(function)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfFunctionWithoutGenerationHasFailedState", func(t *testing.T) {
+
+		function := &CloudFunction{Name: "projects/my-project/locations/europe-west1/functions/my-app", State: "FAILED"}
+
+		// act
+		err := checkFunctionStatus(function)
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// getDefaultMaxInstances returns the maximum number of instances a function gets if it isn't set; gen1 functions report no maximum
+func getDefaultMaxInstances(generation int) int {
+	if generation == 2 {
+		return 100
+	}
+	return 0
+}
+
+// getSecretDescription returns the secret and version a reference points at; describe reports the project by number, so it's left out
+func getSecretDescription(value string) string {
+	reference, err := parseSecretReference(value)
+	if err != nil {
+		return value
+	}
+	return fmt.Sprintf("%v:%v", reference.Secret, reference.Version)
+}
+
+// getDriftChanges returns the differences between the deployed function and the manifest; unlike a plan it includes changes a deployment wouldn't undo, like labels or invokers added outside of the manifest and settings the manifest leaves at their default
+func getDriftChanges(current Params, currentLabels map[string]string, currentInvokers []string, desired Params, desiredLabels map[string]string, desiredInvokers []string) []PlanChange {
+
+	changes := getPlanChanges(current, currentLabels, desired, desiredLabels)
+	addChange := func(field, currentValue, desiredValue, risk string) {
+		if currentValue != desiredValue {
+			changes = append(changes, PlanChange{Field: field, Current: currentValue, Desired: desiredValue, Risk: risk})
+		}
+	}
+
+	if desired.MaxInstances == 0 {
+		addChange("maxInstances", fmt.Sprint(current.MaxInstances), fmt.Sprint(getDefaultMaxInstances(desired.Generation)), "")
+	}
+	if desired.Generation == 2 && desired.Concurrency == 0 {
+		addChange("concurrency", fmt.Sprint(current.Concurrency), "0", "")
+	}
+
+	secretKeys := sortedKeys(current.Secrets)
+	for _, k := range sortedKeys(desired.Secrets) {
+		if _, ok := current.Secrets[k]; !ok {
+			secretKeys = append(secretKeys, k)
+		}
+	}
+	sort.Strings(secretKeys)
+	for _, k := range secretKeys {
+		currentValue, desiredValue := "(unset)", "(unset)"
+		if v, ok := current.Secrets[k]; ok {
+			currentValue = getSecretDescription(v)
+		}
+		if v, ok := desired.Secrets[k]; ok {
+			desiredValue = getSecretDescription(v)
+		}
+		addChange("secrets."+k, currentValue, desiredValue, "")
+	}
+
+	for _, member := range currentInvokers {
+		if inStringArray(member, desiredInvokers) {
+			continue
+		}
+		risk := ""
+		if member == "allUsers" || member == "allAuthenticatedUsers" {
+			risk = "function is public"
+		}
+		changes = append(changes, PlanChange{Field: "invokers." + member, Current: "(set)", Desired: "(unset)", Risk: risk})
+	}
+	for _, member := range desiredInvokers {
+		// public access missing from the function is in the plan already
+		if member == "allUsers" || inStringArray(member, currentInvokers) {
+			continue
+		}
+		changes = append(changes, PlanChange{Field: "invokers." + member, Current: "(unset)", Desired: "(set)"})
+	}
+
+	for _, k := range sortedKeys(currentLabels) {
+		if _, ok := desiredLabels[k]; ok || strings.HasPrefix(k, "deployment") {
+			continue
+		}
+		changes = append(changes, PlanChange{Field: "labels." + k, Current: currentLabels[k], Desired: "(unset)"})
+	}
+
+	return changes
+}
+
+// getDesiredInvokers returns the members the manifest lets invoke the function: everyone if it allows unauthenticated invocations, and the service account the scheduler job signs its requests with if it's scheduled
+func getDesiredInvokers(params Params, function *CloudFunction) []string {
+	invokers := []string{}
+	if params.AllowUnauthenticated {
+		invokers = append(invokers, "allUsers")
+	}
+	if params.Schedule != nil {
+		serviceAccount := params.ServiceAccount
+		if serviceAccount == "" {
+			serviceAccount = function.GetServiceAccountEmail()
+		}
+		invokers = append(invokers, "serviceAccount:"+serviceAccount)
+	}
+	sort.Strings(invokers)
+	return invokers
+}
+
+// getLiveInvokers returns the members allowed to invoke the deployed function; invokers of a gen2 function are granted on its cloud run service. Extra arguments like --project and --account read them in another project
+func getLiveInvokers(ctx context.Context, params Params, region string, function *CloudFunction, extraArguments ...string) ([]string, error) {
+
+	generation := 1
+	if function.IsGen2() {
+		generation = 2
+	}
+
+	policy, err := getIAMPolicy(ctx, params.App, region, generation, extraArguments...)
+	if err != nil {
+		return nil, err
+	}
+
+	if generation == 2 && function.ServiceConfig != nil && function.ServiceConfig.Service != "" {
+		service := function.ServiceConfig.Service[strings.LastIndex(function.ServiceConfig.Service, "/")+1:]
+		output, err := getCommandWithArgsOutput(ctx, "gcloud", append([]string{"run", "services", "get-iam-policy", service, "--region", region, "--format", "json"}, extraArguments...))
+		if err != nil {
+			return nil, err
+		}
+		var servicePolicy IAMPolicy
+		err = json.Unmarshal([]byte(output), &servicePolicy)
+		if err != nil {
+			return nil, err
+		}
+		policy.Bindings = append(policy.Bindings, servicePolicy.Bindings...)
+	}
+
+	return policy.GetInvokers(), nil
+}
+
+// getVerifiedLabels leaves out the labels that differ between runs without drift; the expiry of a preview moves with every deployment, and the build version of a verify run isn't the one that got deployed
+func getVerifiedLabels(labels map[string]string) map[string]string {
+	return withoutLabels(labels, previewExpiresLabel, buildVersionLabel)
+}
+
+// verifyFunction reports drift between the deployed function and the manifest and exits with the drift exit code if there is any
+func verifyFunction(ctx context.Context, params Params, credential GKECredentials, labels map[string]string) {
+
+	function, current, err := describeLiveParams(ctx, params, credential)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed retrieving cloud function %v to verify", params.App)
+	}
+
+	invokers, err := getLiveInvokers(ctx, params, credential.AdditionalProperties.Region, function)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed retrieving invokers of cloud function %v to verify", params.App)
+	}
+
+	changes := getDriftChanges(current, getVerifiedLabels(function.Labels), invokers, params, getVerifiedLabels(getDeployLabels(params, labels, time.Now())), getDesiredInvokers(params, function))
+	if len(changes) == 0 {
+		log.Info().Msgf("Verify: cloud function %v matches the manifest", params.App)
+		return
+	}
+
+	log.Warn().Msgf("Verify: cloud function %v has drifted from the manifest in %v fields", params.App, len(changes))
+	for _, change := range changes {
+		log.Warn().Msgf("  %v", change)
+	}
+
+	os.Exit(driftExitCode)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDriftChanges(t *testing.T) {
+	t.Run("ReturnsNoChangesIfFunctionMatchesManifest", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+
+		// act
+		changes := getDriftChanges(current, map[string]string{"app": "myfunction", "deployment-tool": "cli-gcloud"}, []string{}, desired, map[string]string{"app": "myfunction"}, []string{})
+
+		assert.Equal(t, 0, len(changes))
+	})
+
+	t.Run("ReturnsLabelsAddedOutsideOfManifest", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+
+		// act
+		changes := getDriftChanges(current, map[string]string{"app": "myfunction", "hotfix": "true"}, []string{}, desired, map[string]string{"app": "myfunction"}, []string{})
+
+		assert.Equal(t, []PlanChange{{Field: "labels.hotfix", Current: "true", Desired: "(unset)"}}, changes)
+	})
+
+	t.Run("ReturnsPublicAccessGrantedOutsideOfManifest", func(t *testing.T) {
+
+		current := validParams
+		current.AllowUnauthenticated = true
+		desired := validParams
+
+		// act
+		changes := getDriftChanges(current, map[string]string{}, []string{"allUsers"}, desired, map[string]string{}, []string{})
+
+		assert.Equal(t, []PlanChange{{Field: "invokers.allUsers", Current: "(set)", Desired: "(unset)", Risk: "function is public"}}, changes)
+	})
+
+	t.Run("ReturnsInvokersGrantedOutsideOfManifest", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+
+		// act
+		changes := getDriftChanges(current, map[string]string{}, []string{"serviceAccount:caller@my-project.iam.gserviceaccount.com"}, desired, map[string]string{}, []string{})
+
+		assert.Equal(t, []PlanChange{{Field: "invokers.serviceAccount:caller@my-project.iam.gserviceaccount.com", Current: "(set)", Desired: "(unset)"}}, changes)
+	})
+
+	t.Run("ReturnsInvokersOfManifestMissingFromFunction", func(t *testing.T) {
+
+		current := validParams
+		desired := validParams
+
+		// act
+		changes := getDriftChanges(current, map[string]string{}, []string{}, desired, map[string]string{}, []string{"serviceAccount:myfunction@my-project.iam.gserviceaccount.com"})
+
+		assert.Equal(t, []PlanChange{{Field: "invokers.serviceAccount:myfunction@my-project.iam.gserviceaccount.com", Current: "(unset)", Desired: "(set)"}}, changes)
+	})
+
+	t.Run("ReturnsMaxInstancesIfManifestLeavesItAtDefault", func(t *testing.T) {
+
+		current := validParams
+		current.Generation = 2
+		current.MaxInstances = 10
+		desired := validParams
+		desired.Generation = 2
+
+		// act
+		changes := getDriftChanges(current, map[string]string{}, []string{}, desired, map[string]string{}, []string{})
+
+		assert.Equal(t, []PlanChange{{Field: "maxInstances", Current: "10", Desired: "100"}}, changes)
+	})
+
+	t.Run("ReturnsSecretChanges", func(t *testing.T) {
+
+		current := validParams
+		current.Secrets = map[string]string{"API_KEY": "projects/123456789/secrets/api-key/versions/2", "OLD_KEY": "projects/123456789/secrets/old-key/versions/1"}
+		desired := validParams
+		desired.Secrets = map[string]string{"API_KEY": "api-key:3", "DB_PASSWORD": "db-password"}
+
+		// act
+		changes := getDriftChanges(current, map[string]string{}, []string{}, desired, map[string]string{}, []string{})
+
+		assert.Equal(t, []PlanChange{
+			{Field: "secrets.API_KEY", Current: "api-key:2", Desired: "api-key:3"},
+			{Field: "secrets.DB_PASSWORD", Current: "(unset)", Desired: "db-password:latest"},
+			{Field: "secrets.OLD_KEY", Current: "old-key:1", Desired: "(unset)"},
+		}, changes)
+	})
+
+	t.Run("ReturnsNoSecretChangesIfOnlyProjectNotationDiffers", func(t *testing.T) {
+
+		current := validParams
+		current.Secrets = map[string]string{"API_KEY": "projects/123456789/secrets/api-key/versions/latest"}
+		desired := validParams
+		desired.Secrets = map[string]string{"API_KEY": "api-key"}
+
+		// act
+		changes := getDriftChanges(current, map[string]string{}, []string{}, desired, map[string]string{}, []string{})
+
+		assert.Equal(t, 0, len(changes))
+	})
+
+	t.Run("ReturnsScalingChanges", func(t *testing.T) {
+
+		current := validParams
+		current.MinInstances = 3
+		current.MaxInstances = 10
+		desired := validParams
+		desired.MaxInstances = 5
+
+		// act
+		changes := getDriftChanges(current, map[string]string{}, []string{}, desired, map[string]string{}, []string{})
+
+		assert.Equal(t, []PlanChange{
+			{Field: "minInstances", Current: "3", Desired: "0"},
+			{Field: "maxInstances", Current: "10", Desired: "5"},
+		}, changes)
+	})
+}
+
+func TestGetDesiredInvokers(t *testing.T) {
+	t.Run("ReturnsAllUsersIfManifestAllowsUnauthenticated", func(t *testing.T) {
+
+		params := validParams
+		params.AllowUnauthenticated = true
+
+		// act
+		invokers := getDesiredInvokers(params, &CloudFunction{})
+
+		assert.Equal(t, []string{"allUsers"}, invokers)
+	})
+
+	t.Run("ReturnsRuntimeServiceAccountIfScheduled", func(t *testing.T) {
+
+		params := validParams
+		params.Schedule = &validScheduleParam
+		function := &CloudFunction{ServiceAccountEmail: "my-project@appspot.gserviceaccount.com"}
+
+		// act
+		invokers := getDesiredInvokers(params, function)
+
+		assert.Equal(t, []string{"serviceAccount:my-project@appspot.gserviceaccount.com"}, invokers)
+	})
+}
+
+func TestGetVerifiedLabels(t *testing.T) {
+	t.Run("LeavesOutBuildVersionAndPreviewExpiry", func(t *testing.T) {
+
+		labels := map[string]string{"app": "myfunction", "build-version": "1-0-3", "preview-expires": "1700000000"}
+
+		// act
+		verifiedLabels := getVerifiedLabels(labels)
+
+		assert.Equal(t, map[string]string{"app": "myfunction"}, verifiedLabels)
+	})
+}